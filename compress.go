@@ -0,0 +1,194 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding is what '--compressed' advertises
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// decodedEncodings are the 'Content-Encoding' values newDecoder knows
+var decodedEncodings = []string{"gzip", "x-gzip", "deflate", "br", "zstd"}
+
+// decompressTransport decodes the 'Content-Encoding' of the responses.
+// It sits right above the verbose output, so '-v' shows the headers as sent,
+// and below the failover, which looks for the standby errors in the decoded body.
+type decompressTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (t *decompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || rawMode || !hasBody(req, resp) {
+		return resp, err
+	}
+
+	encodings := []string{}
+	for _, e := range strings.Split(strings.Join(resp.Header.Values("Content-Encoding"), ","), ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+			encodings = append(encodings, e)
+		}
+	}
+	if len(encodings) == 0 {
+		return resp, nil
+	}
+
+	// The encodings were applied in order, they are undone from the last one
+	var body io.Reader = resp.Body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		if !isInSlice(encodings[i], decodedEncodings) {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unable to decode the '%s' response body. Because: unsupported encoding, use '--raw' to save it as-is", encodings[i])
+		}
+		body = &lazyDecoder{encoding: encodings[i], encoded: body, src: bufio.NewReader(body)}
+	}
+
+	resp.Body = &decodedBody{Reader: body, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// hasBody tells if the response can carry an encoded body, the answers to HEAD, 204 & 304 keep the
+// 'Content-Encoding' of the resource without sending it
+func hasBody(req *http.Request, resp *http.Response) bool {
+	switch {
+	case req.Method == http.MethodHead:
+		return false
+	case resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified:
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+// lazyDecoder builds the decoder on the first Read, as the gzip & zlib ones read their header right away.
+// A body without a single byte, like a chunked empty one, stays empty.
+type lazyDecoder struct {
+	encoding string
+	encoded  io.Reader
+	src      *bufio.Reader
+	decoder  io.Reader
+	err      error
+}
+
+func (l *lazyDecoder) Read(p []byte) (int, error) {
+	if l.decoder == nil && l.err == nil {
+		if _, err := l.src.Peek(1); err != nil {
+			l.err = err
+		} else if decoder, err := newDecoder(l.encoding, l.src); err != nil {
+			l.err = fmt.Errorf("unable to decode the '%s' response body. Because: %w", l.encoding, err)
+		} else {
+			l.decoder = decoder
+		}
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	return l.decoder.Read(p)
+}
+
+// Close releases the decoder and the ones of the encodings below it
+func (l *lazyDecoder) Close() error {
+	if c, ok := l.decoder.(io.Closer); ok {
+		c.Close()
+	}
+	if c, ok := l.encoded.(*lazyDecoder); ok {
+		c.Close()
+	}
+	return nil
+}
+
+func newDecoder(encoding string, r io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// 'deflate' should be zlib wrapped, some servers send the raw stream
+		br := bufio.NewReader(r)
+		head, err := br.Peek(2)
+		if err == nil && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding '%s', use '--raw' to save it as-is", encoding)
+	}
+}
+
+// decodedBody closes the network body once the decoded one is read
+type decodedBody struct {
+	io.Reader
+	raw io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	if c, ok := b.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return b.raw.Close()
+}
+
+// gzipBody compresses a request body on the fly, every call streams it again
+func gzipBody(getBody func() (io.ReadCloser, error)) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		body, err := getBody()
+		if err != nil {
+			return nil, err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			defer body.Close()
+
+			zw := gzip.NewWriter(pw)
+			if _, err := io.Copy(zw, body); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.CloseWithError(zw.Close())
+		}()
+
+		return pr, nil
+	}
+}
@@ -0,0 +1,121 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompressTransport(t *testing.T) {
+	const text = "Hadoop:service=NameNode,name=NameNodeStatus"
+
+	encode := func(encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		case "zstd":
+			w, _ = zstd.NewWriter(&buf)
+		}
+		io.WriteString(w, text)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		encoding   string
+		status     int
+		body       []byte
+		chunked    bool
+		wantStatus int
+		wantBody   string
+		wantErr    bool
+	}{
+		{name: "gzip", encoding: "gzip", body: encode("gzip"), wantBody: text},
+		{name: "deflate", encoding: "deflate", body: encode("deflate"), wantBody: text},
+		{name: "br", encoding: "br", body: encode("br"), wantBody: text},
+		{name: "zstd", encoding: "zstd", body: encode("zstd"), wantBody: text},
+		{name: "identity", encoding: "identity", body: []byte(text), wantBody: text},
+		{name: "empty gzip", encoding: "gzip"},
+		{name: "empty chunked gzip", encoding: "gzip", chunked: true},
+		{name: "not modified", encoding: "gzip", status: http.StatusNotModified, wantStatus: http.StatusNotModified},
+		{name: "no content", encoding: "br", status: http.StatusNoContent, wantStatus: http.StatusNoContent},
+		{name: "head", method: http.MethodHead, encoding: "gzip", body: encode("gzip")},
+		{name: "corrupt gzip", encoding: "gzip", body: []byte(text), wantErr: true},
+		{name: "unsupported", encoding: "compress", body: []byte(text), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", tt.encoding)
+			if tt.status != 0 {
+				w.WriteHeader(tt.status)
+			}
+			if tt.chunked {
+				w.(http.Flusher).Flush()
+			}
+			w.Write(tt.body)
+		}))
+
+		method := tt.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		wantStatus := tt.wantStatus
+		if wantStatus == 0 {
+			wantStatus = http.StatusOK
+		}
+
+		req, _ := http.NewRequest(method, srv.URL, nil)
+		client := &http.Client{Transport: &decompressTransport{next: http.DefaultTransport}}
+		resp, err := client.Do(req)
+		var body []byte
+		if err == nil {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		srv.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if resp.StatusCode != wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, wantStatus)
+		}
+		if string(body) != tt.wantBody {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/integrii/flaggy"
+	"gopkg.in/yaml.v3"
+)
+
+// The settings are taken, from the lowest to the highest precedence, from
+// the defaults, the system config, the user config, the 'GURL_*' variables and the flags.
+// Both config files hold named profiles, the user one wins key by key for a profile defined in both.
+const systemConfigFile = "/etc/gurl/config.yaml"
+
+var (
+	configProfile = ""
+	configFiles   []string // the config files that were loaded
+
+	configCmd     = flaggy.NewSubcommand("config")
+	configShowCmd = flaggy.NewSubcommand("show")
+)
+
+// setting is a flag that can also come from a profile & a 'GURL_<KEY>' variable
+type setting struct {
+	key    string      // flag long name, also the profile key
+	value  interface{} // pointer to the global
+	secret bool
+	source string
+}
+
+// settings lists what a profile can hold
+var settings = []*setting{
+	{key: "base-url", value: &baseURL},
+	{key: "kerberized", value: &isKerberized},
+	{key: "keytab-path", value: &keytabPath},
+	{key: "kerberos-principle", value: &kerberosPrinciple},
+	{key: "ts-format", value: &timestampLayout},
+	{key: "basic-auth", value: &isBasicAuth, secret: true},
+	{key: "user-agent", value: &clientUserAgent},
+	{key: "enforce-tls-verify", value: &enforceTLSVerify},
+	{key: "cacert", value: &caCertFile},
+	{key: "header", value: &requestHeaders},
+	{key: "unix-socket", value: &unixSocket},
+	{key: "resolve", value: &resolveEntries},
+	{key: "connect-to", value: &connectToEntries},
+	{key: "hosts-file", value: &hostsFile},
+	{key: "dns-servers", value: &dnsServers},
+	{key: "ipv4", value: &ipv4Only},
+	{key: "ipv6", value: &ipv6Only},
+	{key: "interface", value: &interfaceName},
+	{key: "local-address", value: &localAddress},
+	{key: "proxy", value: &proxyAddr},
+	{key: "proxy-user", value: &proxyUser, secret: true},
+	{key: "proxy-negotiate", value: &proxyNegotiate},
+	{key: "noproxy", value: &noProxy},
+	{key: "connect-timeout", value: &connectTimeout},
+	{key: "max-time", value: &maxTime},
+	{key: "kdc-timeout", value: &kdcTimeout},
+	{key: "retry", value: &retryCount},
+	{key: "limit-rate", value: &limitRateSpec},
+	{key: "pretty", value: &prettyMode},
+	{key: "hdfs-site", value: &hdfsSiteFile},
+	{key: "hdfs-user", value: &hdfsUser},
+}
+
+type configFile struct {
+	DefaultProfile string                            `yaml:"default-profile"`
+	Profiles       map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+func registerConfigCommands() {
+	flaggy.String(&configProfile, "", "profile", "Profile of the config files to use, also set by GURL_PROFILE")
+
+	configCmd.Description = "Inspect the config files & the 'GURL_*' variables"
+	configShowCmd.Description = "Print the settings in effect and where each one comes from"
+	configCmd.AttachSubcommand(configShowCmd, 1)
+	flaggy.AttachSubcommand(configCmd, 1)
+}
+
+func (s *setting) envName() string {
+	return "GURL_" + strings.ToUpper(strings.ReplaceAll(s.key, "-", "_"))
+}
+
+func (s *setting) String() string {
+	switch v := s.value.(type) {
+	case *string:
+		return *v
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *int:
+		return strconv.Itoa(*v)
+	case *time.Duration:
+		return v.String()
+	case *[]string:
+		return strings.Join(*v, ", ")
+	}
+	return ""
+}
+
+// set parses a profile or environment value into the global
+func (s *setting) set(raw interface{}) error {
+	switch v := s.value.(type) {
+	case *[]string:
+		switch list := raw.(type) {
+		case []interface{}:
+			*v = []string{}
+			for _, item := range list {
+				*v = append(*v, fmt.Sprint(item))
+			}
+		default:
+			*v = []string{fmt.Sprint(raw)}
+		}
+		return nil
+	case *string:
+		*v = fmt.Sprint(raw)
+		return nil
+	}
+
+	str := strings.TrimSpace(fmt.Sprint(raw))
+	switch v := s.value.(type) {
+	case *bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a boolean", str)
+		}
+		*v = b
+	case *int:
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a number", str)
+		}
+		*v = n
+	case *time.Duration:
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a duration", str)
+		}
+		*v = d
+	}
+	return nil
+}
+
+func userConfigPath() string {
+	if path := os.Getenv("GURL_CONFIG"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gurl", "config.yaml")
+}
+
+// passedSettings returns the settings given on the command line, even when given their default value.
+// flaggy records the flags it parsed by the name they were typed with, the settings are matched
+// through the variable the flag assigns to.
+func passedSettings() map[*setting]bool {
+	passed := map[*setting]bool{}
+	var walk func(sc *flaggy.Subcommand)
+	walk = func(sc *flaggy.Subcommand) {
+		for _, value := range sc.ParsedValues {
+			if value.IsPositional {
+				continue
+			}
+
+			name, _, _ := strings.Cut(value.Key, "=")
+			for _, s := range settings {
+				if flagAssigns(name, s.value) {
+					passed[s] = true
+				}
+			}
+		}
+		for _, child := range sc.Subcommands {
+			if child.Used {
+				walk(child)
+			}
+		}
+	}
+	walk(&flaggy.DefaultParser.Subcommand)
+	return passed
+}
+
+// flagAssigns tells if the flag named 'name', of the root or of any subcommand, assigns to 'value'
+func flagAssigns(name string, value interface{}) bool {
+	var search func(sc *flaggy.Subcommand) bool
+	search = func(sc *flaggy.Subcommand) bool {
+		for _, f := range sc.Flags {
+			if f.HasName(name) && f.AssignmentVar == value {
+				return true
+			}
+		}
+		for _, child := range sc.Subcommands {
+			if search(child) {
+				return true
+			}
+		}
+		return false
+	}
+	return search(&flaggy.DefaultParser.Subcommand)
+}
+
+// loadSettings applies the selected profile of the config files, then the 'GURL_*' variables,
+// to the settings that no flag changed. The '-H' headers of the profile come before the flag ones.
+// The config files are given from the lowest precedence, the system one first.
+func loadSettings(paths ...string) error {
+	files := []configFile{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		raw, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("unable to read the config file '%s'. Because: %w", path, err)
+		}
+
+		var cfg configFile
+		if err := yaml.Unmarshal(raw, &cfg); err != nil {
+			return fmt.Errorf("unable to parse the config file '%s'. Because: %w", path, err)
+		}
+		files = append(files, cfg)
+		configFiles = append(configFiles, path)
+	}
+
+	if configProfile == "" {
+		configProfile = os.Getenv("GURL_PROFILE")
+	}
+	// The 'default-profile' of the user config beats the system one
+	for i := len(files) - 1; i >= 0 && configProfile == ""; i-- {
+		configProfile = files[i].DefaultProfile
+	}
+
+	// What the flags set is kept aside and put back over the profile
+	passed := passedSettings()
+	fromFlags := map[*setting][]string{}
+	for _, s := range settings {
+		s.source = "default"
+		if list, ok := s.value.(*[]string); ok {
+			fromFlags[s] = *list
+			*list = []string{}
+		} else if passed[s] {
+			s.source = "flag --" + s.key
+		}
+	}
+
+	if configProfile != "" {
+		found := false
+		for i, cfg := range files {
+			if profile, ok := cfg.Profiles[configProfile]; ok {
+				found = true
+				if err := applyProfile(profile, configFiles[i]); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("profile '%s' is not defined in %s", configProfile, describeConfigFiles())
+		}
+	}
+
+	for _, s := range settings {
+		raw, found := os.LookupEnv(s.envName())
+		if !found || strings.HasPrefix(s.source, "flag") {
+			continue
+		}
+
+		var value interface{} = raw
+		if s.isList() {
+			value = splitEnvList(raw)
+		}
+		if err := s.set(value); err != nil {
+			return fmt.Errorf("invalid '%s'. Because: %w", s.envName(), err)
+		}
+		s.source = s.envName()
+	}
+
+	for s, items := range fromFlags {
+		if len(items) == 0 {
+			continue
+		}
+
+		list := s.value.(*[]string)
+		*list = append(*list, items...)
+		if s.source == "default" {
+			s.source = "flag --" + s.key
+		} else {
+			s.source += " + flag --" + s.key
+		}
+	}
+	return nil
+}
+
+func applyProfile(profile map[string]interface{}, path string) error {
+	for key, raw := range profile {
+		var target *setting
+		for _, s := range settings {
+			if s.key == key {
+				target = s
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("unknown key '%s' in the profile '%s' of '%s'", key, configProfile, path)
+		}
+		if strings.HasPrefix(target.source, "flag") {
+			continue
+		}
+
+		if err := target.set(raw); err != nil {
+			return fmt.Errorf("invalid '%s' in the profile '%s' of '%s'. Because: %w", key, configProfile, path, err)
+		}
+		target.source = path
+	}
+	return nil
+}
+
+func (s *setting) isList() bool {
+	_, ok := s.value.(*[]string)
+	return ok
+}
+
+// splitEnvList reads the list values of the variables, one item per line
+func splitEnvList(raw string) []interface{} {
+	items := []interface{}{}
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
+
+func describeConfigFiles() string {
+	if len(configFiles) == 0 {
+		return "any config file ('" + systemConfigFile + "', '" + userConfigPath() + "')"
+	}
+	return "'" + strings.Join(configFiles, "', '") + "'"
+}
+
+// showConfig prints the settings in effect, the secrets are redacted unless '--no-redact'
+func showConfig(w io.Writer) error {
+	profile := configProfile
+	if profile == "" {
+		profile = "(none)"
+	}
+	fmt.Fprintf(w, "Profile: %s\n", profile)
+	if len(configFiles) == 0 {
+		fmt.Fprintf(w, "Config files: none of '%s', '%s'\n\n", systemConfigFile, userConfigPath())
+	} else {
+		fmt.Fprintf(w, "Config files: %s\n\n", describeConfigFiles())
+	}
+
+	sorted := append([]*setting{}, settings...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].key < sorted[j].key })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tVARIABLE")
+	for _, s := range sorted {
+		value := s.String()
+		if s.secret && value != "" && !noRedact {
+			value = "[REDACTED]"
+		}
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.key, value, s.source, s.envName())
+	}
+	return tw.Flush()
+}
+
+// loadCACerts reads the PEM bundle of '--cacert', the system roots are not used then
+func loadCACerts(path string) (*x509.CertPool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the CA file '%s'. Because: %w", path, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, errors.New("no PEM certificate found in the CA file '" + path + "'")
+	}
+	return pool, nil
+}
+
+// withBaseURL resolves a '-l' path like '/jmx' against the 'base-url' of the profile.
+// The base can be an HA list, the path then goes to its first URL like for '-l'.
+func withBaseURL(spec string) string {
+	if baseURL == "" || strings.Contains(spec, "://") {
+		return spec
+	}
+
+	bases := splitURLList(baseURL)
+	bases[0] = strings.TrimRight(bases[0], "/") + "/" + strings.TrimLeft(spec, "/")
+	return strings.Join(bases, ",")
+}
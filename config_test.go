@@ -0,0 +1,93 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSettings(t *testing.T) {
+	saved := map[*setting]interface{}{}
+	for _, s := range settings {
+		saved[s] = reflect.ValueOf(s.value).Elem().Interface()
+	}
+	defer func(profile string, files []string) {
+		for s, v := range saved {
+			reflect.ValueOf(s.value).Elem().Set(reflect.ValueOf(v))
+		}
+		configProfile, configFiles = profile, files
+	}(configProfile, configFiles)
+
+	dir := t.TempDir()
+	system := filepath.Join(dir, "system.yaml")
+	user := filepath.Join(dir, "user.yaml")
+	os.WriteFile(system, []byte(`default-profile: prod-west
+profiles:
+  prod-east:
+    base-url: "https://nn01.east.acme.org:9871"
+    retry: 3
+  prod-west:
+    base-url: "https://nn01.west.acme.org:9871"
+`), 0o644)
+	os.WriteFile(user, []byte(`default-profile: prod-east
+profiles:
+  prod-east:
+    base-url: "https://knox.east.acme.org:8443/gateway/default"
+`), 0o644)
+
+	tests := []struct {
+		name        string
+		profile     string
+		paths       []string
+		wantProfile string
+		wantBaseURL string
+		wantRetry   int
+	}{
+		{
+			name: "user default profile", paths: []string{system, user}, wantProfile: "prod-east",
+			wantBaseURL: "https://knox.east.acme.org:8443/gateway/default", wantRetry: 3,
+		},
+		{
+			name: "system only", paths: []string{system}, wantProfile: "prod-west",
+			wantBaseURL: "https://nn01.west.acme.org:9871",
+		},
+		{
+			name: "profile given", profile: "prod-west", paths: []string{system, user}, wantProfile: "prod-west",
+			wantBaseURL: "https://nn01.west.acme.org:9871",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("GURL_PROFILE", "")
+		configProfile, configFiles, baseURL, retryCount = tt.profile, nil, "", 0
+
+		if err := loadSettings(tt.paths...); err != nil {
+			t.Errorf("%s: loadSettings error = %v", tt.name, err)
+			continue
+		}
+		if configProfile != tt.wantProfile {
+			t.Errorf("%s: profile = %q, want %q", tt.name, configProfile, tt.wantProfile)
+		}
+		if baseURL != tt.wantBaseURL {
+			t.Errorf("%s: base-url = %q, want %q", tt.name, baseURL, tt.wantBaseURL)
+		}
+		if retryCount != tt.wantRetry {
+			t.Errorf("%s: retry = %d, want %d", tt.name, retryCount, tt.wantRetry)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// newDialFunc is the dial of the TCP transport.
+// With '--unix-socket' every connection goes to the socket, the URL still gives the Host, the SPN & the TLS server name
+func newDialFunc() dialFunc {
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second, Resolver: resolver.dns}
+	if unixSocket != "" {
+		return func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", unixSocket)
+		}
+	}
+
+	dial := dialer.DialContext
+	if localIP != nil || localPortFrom > 0 {
+		dial = bindDial(dialer)
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return resolver.dial(ctx, dial, ipNetwork(network), addr)
+	}
+}
+
+// ipNetwork forces the address family of '-4' & '-6', the dialer only tries the addresses of that family
+func ipNetwork(network string) string {
+	switch {
+	case ipv4Only:
+		return network + "4"
+	case ipv6Only:
+		return network + "6"
+	default:
+		return network
+	}
+}
+
+// isInFamily tells if an address can be used with '-4', '-6' & the bound local address
+func isInFamily(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return true
+	}
+
+	v4 := ip.To4() != nil
+	switch {
+	case ipv4Only || localIP != nil && localIP.To4() != nil:
+		return v4
+	case ipv6Only || localIP != nil:
+		return !v4
+	default:
+		return true
+	}
+}
+
+// bindDial connects from the '--interface' or '--local-address' address,
+// and from the first free port of the '--local-port' range
+func bindDial(dialer *net.Dialer) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var conn net.Conn
+		err := bindLocalPort(func(port int) error {
+			d := *dialer
+			d.LocalAddr = &net.TCPAddr{IP: localIP, Port: port}
+
+			var err error
+			conn, err = d.DialContext(ctx, network, addr)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		verbosef("* Local address %s\n", conn.LocalAddr())
+		return conn, nil
+	}
+}
+
+// bindLocalPort calls bind with the ports of '--local-port' until one is free, with 0 when there is no range
+func bindLocalPort(bind func(port int) error) error {
+	if localPortFrom == 0 {
+		return bind(0)
+	}
+
+	for port := localPortFrom; port <= localPortTo; port++ {
+		err := bind(port)
+		if err == nil || !errors.Is(err, syscall.EADDRINUSE) && !errors.Is(err, syscall.EADDRNOTAVAIL) {
+			return err
+		}
+	}
+	return fmt.Errorf("no free local port between %d and %d", localPortFrom, localPortTo)
+}
+
+// parseLocalAddress gives the address to bind, from an IP or from the addresses of a network interface
+func parseLocalAddress(iface string, addr string) (net.IP, error) {
+	if addr != "" {
+		ip := net.ParseIP(strings.Trim(addr, "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid 'local-address' '%s', expected an IP address", addr)
+		}
+		return ip, nil
+	}
+
+	if iface == "" {
+		return nil, nil
+	}
+
+	nic, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use the network interface '%s'. Because: %w", iface, err)
+	}
+	addrs, err := nic.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the addresses of the network interface '%s'. Because: %w", iface, err)
+	}
+
+	// IPv4 first unless '-6' is given, the link-local IPv6 addresses need a zone & are skipped
+	var v6 net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			if !ipv6Only {
+				return ipNet.IP, nil
+			}
+		} else if v6 == nil && !ipv4Only {
+			v6 = ipNet.IP
+		}
+	}
+
+	if v6 == nil {
+		return nil, fmt.Errorf("the network interface '%s' has no usable address", iface)
+	}
+	return v6, nil
+}
+
+// parseLocalPorts reads the 'port' or 'from-to' range of '--local-port'
+func parseLocalPorts(ports string) (int, int, error) {
+	if ports == "" {
+		return 0, 0, nil
+	}
+
+	from, to, isRange := strings.Cut(ports, "-")
+	if !isRange {
+		to = from
+	}
+
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid 'local-port' '%s', expected 'port' or 'from-to'", ports)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil || first < 1 || last > 65535 || first > last {
+		return 0, 0, fmt.Errorf("invalid 'local-port' '%s', expected 'port' or 'from-to'", ports)
+	}
+	return first, last, nil
+}
+
+func validateUnixSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to use the unix socket at: '%s'. Because: %w", path, err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("'%s' is not a unix socket", path)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLocalPorts(t *testing.T) {
+	tests := []struct {
+		ports            string
+		wantFrom, wantTo int
+		wantErr          bool
+	}{
+		{ports: "", wantFrom: 0, wantTo: 0},
+		{ports: "40000", wantFrom: 40000, wantTo: 40000},
+		{ports: "40000-40010", wantFrom: 40000, wantTo: 40010},
+		{ports: " 1 - 65535 ", wantFrom: 1, wantTo: 65535},
+		{ports: "0", wantErr: true},
+		{ports: "65536", wantErr: true},
+		{ports: "40010-40000", wantErr: true},
+		{ports: "40000-", wantErr: true},
+		{ports: "http", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		from, to, err := parseLocalPorts(tt.ports)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLocalPorts(%q) error = %v, wantErr %v", tt.ports, err, tt.wantErr)
+			continue
+		}
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Errorf("parseLocalPorts(%q) = %d, %d, want %d, %d", tt.ports, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	defer func(socket string, kerberized bool, r *hostResolver) {
+		unixSocket, isKerberized, resolver = socket, kerberized, r
+	}(unixSocket, isKerberized, resolver)
+
+	r, err := newHostResolver(nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("newHostResolver error = %v", err)
+	}
+	resolver = r
+
+	// The proxies do not apply to the socket
+	t.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+	t.Setenv("HTTPS_PROXY", "http://127.0.0.1:1")
+
+	// The unix socket paths are short, t.TempDir can be too long for them
+	dir, err := os.MkdirTemp("", "gurl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName := ""
+		if r.TLS != nil {
+			serverName = r.TLS.ServerName
+		}
+		fmt.Fprintf(w, "host=%s sni=%s auth=%s path=%s", r.Host, serverName, r.Header.Get("Authorization"), r.URL.Path)
+	})
+
+	listen := func(name string) (string, *httptest.Server) {
+		path := filepath.Join(dir, name)
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewUnstartedServer(handler)
+		srv.Listener.Close()
+		srv.Listener = l
+		return path, srv
+	}
+
+	plainPath, plain := listen("plain.sock")
+	plain.Start()
+	defer plain.Close()
+
+	tlsPath, secure := listen("tls.sock")
+	secure.StartTLS()
+	defer secure.Close()
+
+	tests := []struct {
+		name       string
+		socket     string
+		url        string
+		kerberized bool
+		want       string
+	}{
+		{name: "http", socket: plainPath, url: "http://docker.example.com/v1.41/info", want: "host=docker.example.com sni= auth= path=/v1.41/info"},
+		{name: "https", socket: tlsPath, url: "https://knox.example.com:8443/gateway", want: "host=knox.example.com:8443 sni=knox.example.com auth= path=/gateway"},
+		{name: "spnego", socket: tlsPath, url: "https://knox.example.com:8443/gateway", kerberized: true, want: "host=knox.example.com:8443 sni=knox.example.com auth=Negotiate token-for-knox.example.com:8443 path=/gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unixSocket, isKerberized = tt.socket, tt.kerberized
+			client := newClient()
+			if tt.kerberized {
+				client.Transport.(*spnegoTransport).spnego = hostToken{}
+			}
+
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatalf("GET %s error = %v", tt.url, err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("GET %s = %q, want %q", tt.url, body, tt.want)
+			}
+		})
+	}
+}
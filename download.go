@@ -0,0 +1,247 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// download writes a body to a local file without ever leaving a half written file at its path.
+// The data goes to a temp file renamed over the path once complete. With '-C -' the temp file
+// is '<path>.part', kept on errors with its validator in '<path>.part.meta' so the next run
+// continues where this one stopped.
+// Devices & pipes like '/dev/null' are written in place, they cannot be renamed over.
+type download struct {
+	path      string
+	part      string
+	direct    bool
+	offset    int64  // bytes already in the '.part' file
+	validator string // ETag or Last-Modified the '.part' file was downloaded with
+	file      *os.File
+}
+
+func newDownload(path string) (*download, error) {
+	d := &download{path: path}
+
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		if info.IsDir() {
+			return nil, fmt.Errorf("the output path '%s' is a directory", path)
+		}
+		if resumeDownload {
+			return nil, fmt.Errorf("cannot resume into '%s', it is not a regular file", path)
+		}
+		d.direct = true
+		return d, nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to access the existing file: '%s'. Because: %w", path, err)
+	}
+
+	if createDirs {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("unable to create the directories of '%s'. Because: %w", path, err)
+		}
+	} else if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("cannot create the output file at: '%s', use '--create-dirs' for the missing directories. Because: %w", path, err)
+	}
+
+	if !resumeDownload {
+		return d, nil
+	}
+
+	d.part = path + ".part"
+	info, err := os.Stat(d.part)
+	if os.IsNotExist(err) {
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to access the partial download: '%s'. Because: %w", d.part, err)
+	}
+
+	meta, _ := os.ReadFile(d.metaPath())
+	d.validator = strings.TrimSpace(string(meta))
+	if d.validator == "" {
+		logf("WARN: '%s' has no ETag or Last-Modified to validate it, downloading again\n", d.part)
+		return d, nil
+	}
+
+	d.offset = info.Size()
+	return d, nil
+}
+
+func (d *download) metaPath() string {
+	return d.part + ".meta"
+}
+
+// setRange asks for the bytes after the partial download, only if it is still the same resource
+func (d *download) setRange(req *http.Request) {
+	if d.offset == 0 {
+		return
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+	req.Header.Set("If-Range", d.validator)
+}
+
+// saveResponse writes the body of a response to a request made with setRange
+func (d *download) saveResponse(resp *http.Response) error {
+	appending := false
+	if d.offset > 0 {
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			start, _, _ := parseContentRange(resp.Header.Get("Content-Range"))
+			if start != d.offset {
+				return withExitCode(exitBadResume, fmt.Errorf("the server continued the download at byte %d, expected %d", start, d.offset))
+			}
+			appending = true
+			logf("INFO: Resuming the download of '%s' at byte %d\n", d.path, d.offset)
+		case http.StatusRequestedRangeNotSatisfiable:
+			// The partial download already has every byte
+			if _, total, _ := parseContentRange(resp.Header.Get("Content-Range")); total == d.offset {
+				return d.commit()
+			}
+			return withExitCode(exitBadResume, fmt.Errorf("the server cannot resume '%s' at byte %d: %s", d.path, d.offset, resp.Status))
+		default:
+			logf("INFO: '%s' changed on the server or cannot be resumed, downloading it again\n", d.path)
+		}
+	}
+
+	validator := resp.Header.Get("ETag")
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+
+	return d.save(resp.Body, appending, validator)
+}
+
+// save streams the body to the file, after the partial download when appending
+func (d *download) save(body io.Reader, appending bool, validator string) error {
+	if err := d.open(appending, validator); err != nil {
+		return err
+	}
+
+	if _, err := d.file.ReadFrom(body); err != nil {
+		d.abort()
+		return asWriteError(fmt.Errorf("unable to download to: '%s'. Because: %w", d.path, err))
+	}
+
+	return d.commit()
+}
+
+func (d *download) open(appending bool, validator string) error {
+	var err error
+	switch {
+	case d.direct:
+		d.file, err = os.OpenFile(d.path, os.O_WRONLY, 0)
+	case d.part == "":
+		d.file, err = os.CreateTemp(filepath.Dir(d.path), "."+filepath.Base(d.path)+".*.tmp")
+	case appending:
+		d.file, err = os.OpenFile(d.part, os.O_WRONLY|os.O_APPEND, 0)
+	default:
+		d.file, err = os.OpenFile(d.part, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err == nil {
+			// Without a validator the next run could not tell if the server still has the same file
+			if validator != "" {
+				err = os.WriteFile(d.metaPath(), []byte(validator+"\n"), 0o644)
+			} else {
+				os.Remove(d.metaPath())
+			}
+		}
+	}
+
+	if err != nil {
+		return withExitCode(exitWriteError, fmt.Errorf("unable to create the output file at: '%s'. Because: %w", d.path, err))
+	}
+	return nil
+}
+
+// commit moves the complete download to its path
+func (d *download) commit() error {
+	if d.file != nil {
+		if err := d.file.Close(); err != nil {
+			d.abort()
+			return withExitCode(exitWriteError, fmt.Errorf("unable to write the output file at: '%s'. Because: %w", d.path, err))
+		}
+	}
+
+	if d.direct {
+		return nil
+	}
+
+	tmp := d.part
+	if tmp == "" {
+		tmp = d.file.Name()
+	}
+
+	// The temp files are private, the output gets the usual mode or keeps the one it had
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(d.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	os.Chmod(tmp, mode)
+
+	if err := os.Rename(tmp, d.path); err != nil {
+		d.abort()
+		return withExitCode(exitWriteError, fmt.Errorf("unable to move the download to: '%s'. Because: %w", d.path, err))
+	}
+
+	if d.part != "" {
+		os.Remove(d.metaPath())
+	}
+	return nil
+}
+
+// abort drops the temp file, a '.part' file is kept for the next '-C -' unless '--remove-on-error'
+func (d *download) abort() {
+	if d.file != nil {
+		d.file.Close()
+	}
+
+	switch {
+	case d.direct:
+	case d.part == "":
+		if d.file != nil {
+			os.Remove(d.file.Name())
+		}
+	case removeOnError:
+		os.Remove(d.part)
+		os.Remove(d.metaPath())
+	default:
+		logf("INFO: Partial download kept at '%s', run again with '-C -' to continue\n", d.part)
+	}
+}
+
+// parseContentRange reads 'bytes 100-199/1000' and 'bytes */1000', unknown values are -1
+func parseContentRange(value string) (int64, int64, error) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !found {
+		return -1, -1, errors.New("invalid Content-Range '" + value + "'")
+	}
+
+	span, size, _ := strings.Cut(spec, "/")
+	start, total := int64(-1), int64(-1)
+	if first, _, ok := strings.Cut(span, "-"); ok {
+		start, _ = strconv.ParseInt(first, 10, 64)
+	}
+	if size != "*" {
+		total, _ = strconv.ParseInt(size, 10, 64)
+	}
+	return start, total, nil
+}
@@ -0,0 +1,43 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value                string
+		wantStart, wantTotal int64
+		wantErr              bool
+	}{
+		{value: "bytes 100-199/1000", wantStart: 100, wantTotal: 1000},
+		{value: " bytes 0-0/1 ", wantStart: 0, wantTotal: 1},
+		{value: "bytes 100-199/*", wantStart: 100, wantTotal: -1},
+		{value: "bytes */1000", wantStart: -1, wantTotal: 1000},
+		{value: "items 0-9/10", wantStart: -1, wantTotal: -1, wantErr: true},
+		{value: "", wantStart: -1, wantTotal: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		start, total, err := parseContentRange(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseContentRange(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if start != tt.wantStart || total != tt.wantTotal {
+			t.Errorf("parseContentRange(%q) = %d, %d, want %d, %d", tt.value, start, total, tt.wantStart, tt.wantTotal)
+		}
+	}
+}
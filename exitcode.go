@@ -0,0 +1,199 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// Exit codes, the same as curl where they overlap. The invalid options exit with 2.
+const (
+	exitOK               = 0
+	exitFailure          = 1
+	exitMalformedURL     = 3
+	exitResolveProxy     = 5
+	exitResolveHost      = 6
+	exitConnect          = 7
+	exitPartialFile      = 18
+	exitHTTPError        = 22
+	exitWriteError       = 23
+	exitReadError        = 26
+	exitTimeout          = 28
+	exitTLSConnect       = 35
+	exitBadResume        = 36
+	exitTooManyRedirects = 47
+	exitEmptyReply       = 52
+	exitSendError        = 55
+	exitRecvError        = 56
+	exitPeerCert         = 60
+	exitKerberos         = 67
+	exitProxyHandshake   = 97
+)
+
+// exitClasses name the exit codes in the '--json-result' records
+var exitClasses = map[int]string{
+	exitFailure:          "failure",
+	exitMalformedURL:     "malformed_url",
+	exitResolveProxy:     "resolve_proxy",
+	exitResolveHost:      "resolve_host",
+	exitConnect:          "connect",
+	exitPartialFile:      "partial_file",
+	exitHTTPError:        "http_error",
+	exitWriteError:       "write_error",
+	exitReadError:        "read_error",
+	exitTimeout:          "timeout",
+	exitTLSConnect:       "tls_handshake",
+	exitBadResume:        "bad_resume",
+	exitTooManyRedirects: "too_many_redirects",
+	exitEmptyReply:       "empty_reply",
+	exitSendError:        "send_error",
+	exitRecvError:        "recv_error",
+	exitPeerCert:         "peer_certificate",
+	exitKerberos:         "kerberos",
+	exitProxyHandshake:   "proxy_handshake",
+}
+
+func exitClass(code int) string {
+	return exitClasses[code]
+}
+
+// exitError gives the exit code of an error when the error itself does not tell it
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// asWriteError is for the errors of writing the output, the read side errors keep their code
+func asWriteError(err error) error {
+	if err == nil || exitCode(err) != exitFailure {
+		return err
+	}
+	return withExitCode(exitWriteError, err)
+}
+
+// exitCode is the exit code of the run for an error, 1 when it matches no other
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return exitTimeout
+	}
+
+	var krbErr *Error
+	if errors.As(err, &krbErr) {
+		return exitKerberos
+	}
+
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		var dialErr *net.OpError
+		switch {
+		case errors.As(opErr.Err, &dnsErr):
+			return exitResolveProxy
+		case errors.As(opErr.Err, &dialErr) && dialErr.Op == "dial":
+			return exitConnect
+		case strings.HasPrefix(proxyAddr, "socks5"):
+			return exitProxyHandshake
+		default:
+			return exitRecvError
+		}
+	}
+
+	if errors.As(err, &dnsErr) {
+		return exitResolveHost
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &verifyErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return exitPeerCert
+	}
+
+	var alertErr tls.AlertError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &alertErr) || errors.As(err, &recordErr) {
+		return exitTLSConnect
+	}
+
+	if errors.As(err, &opErr) {
+		switch opErr.Op {
+		case "dial":
+			return exitConnect
+		case "read":
+			return exitRecvError
+		case "write":
+			return exitSendError
+		}
+	}
+
+	// The connection was closed before any response
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return exitEmptyReply
+	}
+
+	return exitFailure
+}
+
+// receivedBody tags the errors of a response body, a cut transfer is a partial file
+type receivedBody struct {
+	io.ReadCloser
+}
+
+func (b *receivedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, withExitCode(exitPartialFile, err)
+	}
+	if code := exitCode(err); code != exitFailure && code != exitEmptyReply {
+		return n, err
+	}
+	return n, withExitCode(exitRecvError, err)
+}
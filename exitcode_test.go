@@ -0,0 +1,114 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	defer func(saved string) { proxyAddr = saved }(proxyAddr)
+	proxyAddr = "http://proxy:3128"
+
+	dnsErr := &net.DNSError{Err: "no such host", Name: "nn1"}
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "no error", err: nil, want: exitOK},
+		{name: "unknown", err: errors.New("boom"), want: exitFailure},
+		{name: "explicit code", err: withExitCode(exitHTTPError, errors.New("404")), want: exitHTTPError},
+		{name: "wrapped explicit code", err: fmt.Errorf("get: %w", withExitCode(exitBadResume, io.EOF)), want: exitBadResume},
+		{name: "deadline", err: fmt.Errorf("get: %w", context.DeadlineExceeded), want: exitTimeout},
+		{name: "kerberos", err: &Error{Err: errors.New("no ticket")}, want: exitKerberos},
+		{name: "dns", err: &net.OpError{Op: "dial", Net: "tcp", Err: dnsErr}, want: exitResolveHost},
+		{name: "dial", err: dialErr, want: exitConnect},
+		{name: "read", err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("reset")}, want: exitRecvError},
+		{name: "write", err: &net.OpError{Op: "write", Net: "tcp", Err: errors.New("broken pipe")}, want: exitSendError},
+		{name: "proxy dns", err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: dnsErr}, want: exitResolveProxy},
+		{name: "proxy dial", err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: dialErr}, want: exitConnect},
+		{name: "proxy reply", err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: io.EOF}, want: exitRecvError},
+		{name: "unknown authority", err: fmt.Errorf("tls: %w", x509.UnknownAuthorityError{}), want: exitPeerCert},
+		{name: "empty reply", err: fmt.Errorf("get: %w", io.EOF), want: exitEmptyReply},
+		{name: "write error", err: asWriteError(errors.New("disk full")), want: exitWriteError},
+		{name: "write error keeps read code", err: asWriteError(withExitCode(exitPartialFile, io.ErrUnexpectedEOF)), want: exitPartialFile},
+	}
+
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestMain runs gurl itself when GURL_TEST_MAIN is set, the test binary is then called with the gurl arguments
+func TestMain(m *testing.M) {
+	if os.Getenv("GURL_TEST_MAIN") == "1" {
+		os.Args[0] = "gurl"
+		main()
+		os.Exit(exitOK)
+	}
+	os.Exit(m.Run())
+}
+
+// runGurl runs gurl with args, without the settings of a config file, and returns its output & its exit code
+func runGurl(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GURL_TEST_MAIN=1", "GURL_CONFIG="+t.TempDir()+"/config.yaml")
+	var outb, errb bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &outb, &errb
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("unable to run gurl %q. Because: %v", args, err)
+	}
+	return outb.String(), errb.String(), code
+}
+
+// The invalid options exit right away, gurl is run as a separate process
+func TestUsageExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "invalid method", args: []string{"-X", "BAD METHOD", "-l", "http://127.0.0.1:1/"}, want: 2},
+		{name: "no url", args: []string{"-X", "GET"}, want: 2},
+		{name: "negative max-redirs", args: []string{"--max-redirs", "-1", "-l", "http://127.0.0.1:1/"}, want: 2},
+		{name: "extension method", args: []string{"-X", "PURGE", "--connect-timeout", "2s", "-l", "http://127.0.0.1:1/"}, want: exitConnect},
+	}
+
+	for _, tt := range tests {
+		if _, _, got := runGurl(t, tt.args...); got != tt.want {
+			t.Errorf("%s: gurl %q exited with %d, want %d", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
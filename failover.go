@@ -0,0 +1,361 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	netURL "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The '-l' value can hold several URLs, a new one starts at every ',http(s)://'.
+// Commas inside a query string are left alone.
+var urlListSeparator = regexp.MustCompile(`,\s*(?:https?://)`)
+
+// splitURLList splits 'http://nn1:9870/jmx,http://nn2:9870' into its URLs
+func splitURLList(list string) []string {
+	urls := []string{}
+	start := 0
+	for _, loc := range urlListSeparator.FindAllStringIndex(list, -1) {
+		urls = append(urls, strings.TrimSpace(list[start:loc[0]]))
+		start = loc[0] + 1
+	}
+	return append(urls, strings.TrimSpace(list[start:]))
+}
+
+// resolveEndpoints returns the URL to request and the HA endpoints (scheme://host:port) it can fail over to.
+// The path & query of the first URL are used for every endpoint.
+// When the host of the first URL is a nameservice from 'hdfs-site.xml', its NameNodes are the endpoints.
+func resolveEndpoints(list, hdfsSite string) (string, []*netURL.URL, error) {
+	urls := splitURLList(list)
+
+	first, err := netURL.Parse(urls[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	endpoints := []*netURL.URL{}
+	if len(urls) == 1 && hdfsSite != "" {
+		addrs, err := nameserviceAddresses(hdfsSite, first.Hostname(), first.Scheme)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, addr := range addrs {
+			endpoints = append(endpoints, &netURL.URL{Scheme: first.Scheme, Host: addr})
+		}
+	}
+
+	if len(endpoints) == 0 {
+		for _, u := range urls {
+			parsed, err := netURL.Parse(u)
+			if err != nil {
+				return "", nil, err
+			}
+			if parsed.Scheme == "" || parsed.Host == "" {
+				return "", nil, fmt.Errorf("'%s' is not an absolute URL", u)
+			}
+			endpoints = append(endpoints, &netURL.URL{Scheme: parsed.Scheme, Host: parsed.Host})
+		}
+	}
+
+	// The request itself is built against the first endpoint
+	first.Scheme, first.Host = endpoints[0].Scheme, endpoints[0].Host
+
+	return first.String(), endpoints, nil
+}
+
+// nameserviceAddresses reads the NameNode web addresses of an HA nameservice, nothing is returned
+// when the file is missing or the host is not a nameservice
+func nameserviceAddresses(hdfsSite, nameservice, scheme string) ([]string, error) {
+	raw, err := os.ReadFile(hdfsSite)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read '%s'. Because: %w", hdfsSite, err)
+	}
+
+	var conf struct {
+		Properties []struct {
+			Name  string `xml:"name"`
+			Value string `xml:"value"`
+		} `xml:"property"`
+	}
+	if err := xml.Unmarshal(raw, &conf); err != nil {
+		return nil, fmt.Errorf("unable to parse '%s'. Because: %w", hdfsSite, err)
+	}
+
+	props := map[string]string{}
+	for _, p := range conf.Properties {
+		props[strings.TrimSpace(p.Name)] = strings.TrimSpace(p.Value)
+	}
+
+	if !isInSlice(nameservice, strings.Split(props["dfs.nameservices"], ",")) {
+		return nil, nil
+	}
+
+	key := "dfs.namenode.http-address."
+	if scheme == "https" {
+		key = "dfs.namenode.https-address."
+	}
+
+	addrs := []string{}
+	for _, nn := range strings.Split(props["dfs.ha.namenodes."+nameservice], ",") {
+		if addr := props[key+nameservice+"."+strings.TrimSpace(nn)]; addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("nameservice '%s' has no '%s*' addresses in '%s'", nameservice, key, hdfsSite)
+	}
+	return addrs, nil
+}
+
+// failoverTransport sends the requests made to the first endpoint to each HA endpoint in turn,
+// starting with the last known active one, until one of them is not a standby.
+// Requests to any other host (DataNode redirects) go straight through.
+type failoverTransport struct {
+	next      http.RoundTripper
+	endpoints []*netURL.URL
+	stateKey  string
+}
+
+func newFailoverTransport(next http.RoundTripper, endpoints []*netURL.URL) *failoverTransport {
+	sorted := []string{}
+	for _, e := range endpoints {
+		sorted = append(sorted, e.String())
+	}
+	sort.Strings(sorted)
+
+	return &failoverTransport{next: next, endpoints: endpoints, stateKey: strings.Join(sorted, ",")}
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	primary := t.endpoints[0]
+	if req.URL.Scheme != primary.Scheme || req.URL.Host != primary.Host {
+		return t.next.RoundTrip(req)
+	}
+
+	order := t.ordered()
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	var lastErr error
+	for i, endpoint := range order {
+		isLast := i == len(order)-1 || !replayable
+
+		r := req.Clone(req.Context())
+		r.URL.Scheme, r.URL.Host = endpoint.Scheme, endpoint.Host
+		if r.Host == primary.Host {
+			r.Host = ""
+		}
+		if i > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(r)
+		if err != nil {
+			lastErr = err
+			if isLast {
+				break
+			}
+			logf("WARN: '%s' is not reachable, failing over. Because: %s\n", endpoint, err)
+			continue
+		}
+
+		reason := t.standbyReason(resp)
+		if reason == "" || isLast {
+			if reason == "" {
+				saveActiveEndpoint(t.stateKey, endpoint.String())
+			}
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		logf("WARN: '%s' %s, failing over\n", endpoint, reason)
+	}
+
+	return nil, lastErr
+}
+
+// ordered puts the last known active endpoint first
+func (t *failoverTransport) ordered() []*netURL.URL {
+	active := loadActiveEndpoint(t.stateKey)
+
+	order := []*netURL.URL{}
+	for _, e := range t.endpoints {
+		if e.String() == active {
+			order = append([]*netURL.URL{e}, order...)
+		} else {
+			order = append(order, e)
+		}
+	}
+	return order
+}
+
+// standbyReason recognises the answers of a standby service: the StandbyException & RetriableException
+// of the WebHDFS RemoteException, also proxied by Knox, the standby YARN RM redirect or refresh page,
+// and a Knox gateway that cannot reach its backend. A successful answer is never read, it is the active one.
+func (t *failoverTransport) standbyReason(resp *http.Response) string {
+	if resp.StatusCode <= 299 {
+		return ""
+	}
+
+	if location := resp.Header.Get("Location"); location != "" {
+		if target, err := resp.Request.URL.Parse(location); err == nil {
+			for _, e := range t.endpoints {
+				if target.Host == e.Host && target.Host != resp.Request.URL.Host {
+					return "redirects to the active instance"
+				}
+			}
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if isKnoxGateway(resp.Request.URL) {
+			return "answered " + resp.Status + " through the Knox gateway"
+		}
+	}
+
+	// The error bodies are small, read the start of it and put it back for the output
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+
+	var remote struct {
+		RemoteException struct {
+			Exception string `json:"exception"`
+		} `json:"RemoteException"`
+	}
+	if err := json.Unmarshal(head, &remote); err == nil {
+		switch remote.RemoteException.Exception {
+		case "StandbyException":
+			return "is a standby (StandbyException)"
+		case "RetriableException":
+			return "cannot serve yet (RetriableException)"
+		}
+	}
+
+	// A standby RM that does not know the active one yet answers a page refreshing itself,
+	// like 'This is standby RM. Can not find any active RM. Will retry in next 5 seconds.'
+	if resp.Header.Get("Refresh") != "" && bytes.Contains(head, []byte("active RM")) {
+		return "is a standby RM"
+	}
+	return ""
+}
+
+// isKnoxGateway tells if the URL goes through a Knox topology, like 'https://knox:8443/gateway/default/webhdfs/v1'
+func isKnoxGateway(u *netURL.URL) bool {
+	return strings.HasPrefix(u.Path, "/gateway/")
+}
+
+func haStatePath() string {
+	if haStateFile != "" {
+		return haStateFile
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gurl", "ha-state.json")
+}
+
+// haStateMu serialises the updates of the state file by the parallel transfers
+var haStateMu sync.Mutex
+
+// loadActiveEndpoint reads the last active endpoint, the state is only a hint so errors are ignored
+func loadActiveEndpoint(key string) string {
+	haStateMu.Lock()
+	defer haStateMu.Unlock()
+	return readHAState(haStatePath())[key]
+}
+
+func readHAState(path string) map[string]string {
+	state := map[string]string{}
+	if raw, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(raw, &state)
+	}
+	return state
+}
+
+// saveActiveEndpoint replaces the state file with a renamed temporary one, so a reader never sees it half written
+func saveActiveEndpoint(key, endpoint string) {
+	path := haStatePath()
+	if path == "" {
+		return
+	}
+
+	haStateMu.Lock()
+	defer haStateMu.Unlock()
+
+	state := readHAState(path)
+	if state[key] == endpoint {
+		return
+	}
+	state[key] = endpoint
+
+	if err := writeHAState(path, state); err != nil {
+		logf("WARN: unable to save the active endpoint to '%s'. Because: %s\n", path, err)
+	}
+}
+
+func writeHAState(path string, state map[string]string) error {
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
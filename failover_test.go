@@ -0,0 +1,97 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	netURL "net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestFailoverTransport(t *testing.T) {
+	defer func(saved bool, file string) { silentMode, haStateFile = saved, file }(silentMode, haStateFile)
+	silentMode = true
+
+	// The metrics of an active NameNode count the StandbyExceptions it has thrown
+	const jmx = `{"beans":[{"name":"Hadoop:service=NameNode,name=RpcDetailedActivityForPort8020","StandbyExceptionNumOps":3}]}`
+	const standby = `{"RemoteException":{"exception":"StandbyException","javaClassName":"org.apache.hadoop.ipc.StandbyException",` +
+		`"message":"Operation category READ is not supported in state standby"}}`
+
+	serve := func(status int, header http.Header, body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	active := serve(http.StatusOK, nil, jmx)
+	standbyNN := serve(http.StatusForbidden, nil, standby)
+	retriable := serve(http.StatusForbidden, nil, `{"RemoteException":{"exception":"RetriableException","message":"safe mode"}}`)
+	unavailable := serve(http.StatusServiceUnavailable, nil, "down")
+	standbyRM := serve(http.StatusTemporaryRedirect, http.Header{"Refresh": {"5;url=/ws/v1/cluster"}},
+		"This is standby RM. Can not find any active RM. Will retry in next 5 seconds.")
+	notFound := serve(http.StatusNotFound, nil, `{"RemoteException":{"exception":"FileNotFoundException","message":"StandbyException"}}`)
+
+	tests := []struct {
+		name       string
+		path       string
+		endpoints  []*httptest.Server
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "active first", path: "/jmx", endpoints: []*httptest.Server{active, standbyNN}, wantStatus: http.StatusOK, wantBody: jmx},
+		{name: "standby first", path: "/jmx", endpoints: []*httptest.Server{standbyNN, active}, wantStatus: http.StatusOK, wantBody: jmx},
+		{name: "retriable first", path: "/jmx", endpoints: []*httptest.Server{retriable, active}, wantStatus: http.StatusOK, wantBody: jmx},
+		{name: "standby RM", path: "/ws/v1/cluster", endpoints: []*httptest.Server{standbyRM, active}, wantStatus: http.StatusOK, wantBody: jmx},
+		{name: "knox gateway down", path: "/gateway/default/jmx", endpoints: []*httptest.Server{unavailable, active}, wantStatus: http.StatusOK, wantBody: jmx},
+		{name: "service unavailable", path: "/jmx", endpoints: []*httptest.Server{unavailable, active}, wantStatus: http.StatusServiceUnavailable, wantBody: "down"},
+		{name: "other remote exception", path: "/jmx", endpoints: []*httptest.Server{notFound, active}, wantStatus: http.StatusNotFound},
+		{name: "all standby", path: "/jmx", endpoints: []*httptest.Server{standbyNN, standbyNN}, wantStatus: http.StatusForbidden, wantBody: standby},
+	}
+
+	for _, tt := range tests {
+		haStateFile = filepath.Join(t.TempDir(), "ha-state.json")
+
+		endpoints := []*netURL.URL{}
+		for _, srv := range tt.endpoints {
+			u, _ := netURL.Parse(srv.URL)
+			endpoints = append(endpoints, u)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, tt.endpoints[0].URL+tt.path, nil)
+		resp, err := newFailoverTransport(http.DefaultTransport, endpoints).RoundTrip(req)
+		if err != nil {
+			t.Errorf("%s: RoundTrip error = %v", tt.name, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+		if tt.wantBody != "" && string(body) != tt.wantBody {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formPart is a single curl style '-F' field
+//
+//	name=value                         plain text value
+//	name=@path;type=...;filename=...   file upload
+//	name=<path;type=...                text value read from a file
+type formPart struct {
+	name        string
+	value       string
+	path        string
+	isFile      bool
+	filename    string
+	contentType string
+}
+
+func parseFormPart(field string) (formPart, error) {
+	name, value, found := strings.Cut(field, "=")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return formPart{}, fmt.Errorf("invalid form field '%s', expected 'name=value'", field)
+	}
+
+	part := formPart{name: name}
+	if !strings.HasPrefix(value, "@") && !strings.HasPrefix(value, "<") {
+		part.value = value
+		return part, nil
+	}
+
+	part.isFile = strings.HasPrefix(value, "@")
+	attrs := strings.Split(value[1:], ";")
+	part.path = strings.TrimSpace(attrs[0])
+	if part.path == "" {
+		return formPart{}, fmt.Errorf("invalid form field '%s', missing file path", field)
+	}
+
+	for _, attr := range attrs[1:] {
+		k, v, _ := strings.Cut(attr, "=")
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "type":
+			part.contentType = strings.TrimSpace(v)
+		case "filename":
+			part.filename = strings.Trim(strings.TrimSpace(v), `"`)
+		default:
+			return formPart{}, fmt.Errorf("invalid form field '%s', unknown attribute '%s'", field, k)
+		}
+	}
+
+	if part.isFile {
+		if part.filename == "" {
+			part.filename = filepath.Base(part.path)
+		}
+		if part.contentType == "" {
+			part.contentType = "application/octet-stream"
+		}
+	}
+
+	return part, nil
+}
+
+// formBody streams a multipart/form-data body straight from the disk.
+// Every call to Reader starts a new pass over the parts, so the body
+// can be sent again when the server asks for authentication or redirects.
+type formBody struct {
+	parts    []formPart
+	boundary string
+}
+
+func newFormBody(fields []string) (*formBody, error) {
+	body := &formBody{boundary: multipart.NewWriter(io.Discard).Boundary()}
+
+	for _, field := range fields {
+		part, err := parseFormPart(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if part.path != "" {
+			if _, err := os.Stat(part.path); err != nil {
+				return nil, withExitCode(exitReadError, fmt.Errorf("cannot find or access the form file '%s'. Because: %w", part.path, err))
+			}
+		}
+		body.parts = append(body.parts, part)
+	}
+
+	return body, nil
+}
+
+func (f *formBody) ContentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+// Reader returns a fresh stream of the encoded body
+func (f *formBody) Reader() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(f.writeTo(pw))
+	}()
+
+	return pr, nil
+}
+
+func (f *formBody) writeTo(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(f.boundary); err != nil {
+		return err
+	}
+
+	for _, part := range f.parts {
+		h := make(textproto.MIMEHeader)
+		disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(part.name))
+		if part.isFile {
+			disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(part.filename))
+		}
+		h.Set("Content-Disposition", disposition)
+		if part.contentType != "" {
+			h.Set("Content-Type", part.contentType)
+		}
+
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+
+		if part.path == "" {
+			if _, err := io.WriteString(pw, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := copyFile(pw, part.path); err != nil {
+			return withExitCode(exitReadError, fmt.Errorf("unable to stream the form file '%s'. Because: %w", part.path, err))
+		}
+	}
+
+	return mw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
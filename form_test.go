@@ -0,0 +1,54 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestParseFormPart(t *testing.T) {
+	tests := []struct {
+		field   string
+		want    formPart
+		wantErr bool
+	}{
+		{field: "name=value", want: formPart{name: "name", value: "value"}},
+		{field: " name =a,b=c", want: formPart{name: "name", value: "a,b=c"}},
+		{field: "empty=", want: formPart{name: "empty"}},
+		{
+			field: "jar=@/tmp/app.jar",
+			want:  formPart{name: "jar", path: "/tmp/app.jar", isFile: true, filename: "app.jar", contentType: "application/octet-stream"},
+		},
+		{
+			field: "jar=@/tmp/app.jar;type=application/java-archive;filename=\"job.jar\"",
+			want:  formPart{name: "jar", path: "/tmp/app.jar", isFile: true, filename: "job.jar", contentType: "application/java-archive"},
+		},
+		{field: "conf=</etc/hadoop/conf/core-site.xml", want: formPart{name: "conf", path: "/etc/hadoop/conf/core-site.xml"}},
+		{field: "conf=<core-site.xml;type=text/xml", want: formPart{name: "conf", path: "core-site.xml", contentType: "text/xml"}},
+		{field: "novalue", wantErr: true},
+		{field: "=value", wantErr: true},
+		{field: "file=@", wantErr: true},
+		{field: "file=@/tmp/a;size=1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFormPart(tt.field)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFormPart(%q) error = %v, wantErr %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseFormPart(%q) = %+v, want %+v", tt.field, got, tt.want)
+		}
+	}
+}
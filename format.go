@@ -0,0 +1,225 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// jqCode is the compiled '--jq' query, only set while parsing the flags
+var jqCode *gojq.Code
+
+func compileJQ(query string) (*gojq.Code, error) {
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'jq' query '%s'. Because: %w", query, err)
+	}
+
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'jq' query '%s'. Because: %w", query, err)
+	}
+	return code, nil
+}
+
+// formatBody writes the body through '--jq' or '--pretty', the whole body is read first
+func formatBody(ctx context.Context, w io.Writer, resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read the response body. Because: %w", err)
+	}
+
+	if jqCode != nil {
+		return runJQ(ctx, w, body)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		// json.Indent keeps the trailing newline of the body, there is only one after the indented value
+		var out bytes.Buffer
+		if err := json.Indent(&out, bytes.TrimSpace(body), "", "  "); err == nil {
+			body = append(out.Bytes(), '\n')
+		}
+	case strings.HasSuffix(mediaType, "xml"):
+		if out, err := indentXML(body); err == nil {
+			body = out
+		}
+	}
+
+	// A body that is not valid is written as it came
+	_, err = w.Write(body)
+	return asWriteError(err)
+}
+
+// runJQ runs the query on every JSON value of the body, the results are indented like jq does
+func runJQ(ctx context.Context, w io.Writer, body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	for {
+		var value interface{}
+		if err := dec.Decode(&value); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("the response body is not JSON, 'jq' cannot be used. Because: %w", err)
+		}
+
+		iter := jqCode.RunWithContext(ctx, value)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+
+			if err, ok := v.(error); ok {
+				var haltErr *gojq.HaltError
+				if errors.As(err, &haltErr) {
+					return haltJQ(haltErr)
+				}
+				return fmt.Errorf("the 'jq' query failed. Because: %w", err)
+			}
+
+			if err := writeJQValue(w, v); err != nil {
+				return asWriteError(err)
+			}
+		}
+	}
+}
+
+// haltJQ ends the query like jq does: 'halt' stops quietly, 'halt_error' prints its value on stderr,
+// a string as it is, and exits with its status, 5 unless the query gave another one
+func haltJQ(halt *gojq.HaltError) error {
+	if v := halt.Value(); v != nil {
+		if s, ok := v.(string); ok {
+			fmt.Fprint(os.Stderr, s)
+		} else if encoded, err := gojq.Marshal(v); err == nil {
+			fmt.Fprintf(os.Stderr, "%s\n", encoded)
+		}
+	}
+
+	if halt.ExitCode() == exitOK {
+		return nil
+	}
+	return withExitCode(halt.ExitCode(), fmt.Errorf("the 'jq' query stopped with halt_error, exit status %d", halt.ExitCode()))
+}
+
+func writeJQValue(w io.Writer, v interface{}) error {
+	if s, ok := v.(string); ok && jqRawOutput {
+		_, err := io.WriteString(w, s+"\n")
+		return err
+	}
+
+	encoded, err := gojq.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, encoded, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// indentXML writes the tokens again with an indent, the text between the elements is trimmed.
+// The raw tokens keep the namespace prefixes as they were written.
+func indentXML(body []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	var out bytes.Buffer
+
+	depth := 0
+	inline := false // the last token was a start tag or text, the end tag stays on its line
+	newline := func() {
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString(strings.Repeat("  ", depth))
+	}
+
+	for {
+		token, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			newline()
+			out.WriteString("<" + qualifiedName(t.Name))
+			for _, a := range t.Attr {
+				out.WriteString(" " + qualifiedName(a.Name) + `="`)
+				xml.EscapeText(&out, []byte(a.Value))
+				out.WriteByte('"')
+			}
+			out.WriteByte('>')
+			depth++
+			inline = true
+		case xml.EndElement:
+			depth--
+			if !inline {
+				newline()
+			}
+			out.WriteString("</" + qualifiedName(t.Name) + ">")
+			inline = false
+		case xml.CharData:
+			if text := bytes.TrimSpace(t); len(text) > 0 {
+				xml.EscapeText(&out, text)
+				inline = true
+			}
+		case xml.Comment:
+			newline()
+			out.WriteString("<!--" + string(t) + "-->")
+			inline = false
+		case xml.ProcInst:
+			newline()
+			out.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			newline()
+			out.WriteString("<!" + string(t) + ">")
+		}
+	}
+
+	if depth != 0 {
+		return nil, errors.New("unexpected end of the XML document")
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,86 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIndentXML(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{body: "<a><b>1</b><c/></a>", want: "<a>\n  <b>1</b>\n  <c></c>\n</a>\n"},
+		{
+			body: "<?xml version=\"1.0\"?>\n<conf>\n  <property>  <name>fs.defaultFS</name> </property>\n</conf>",
+			want: "<?xml version=\"1.0\"?>\n<conf>\n  <property>\n    <name>fs.defaultFS</name>\n  </property>\n</conf>\n",
+		},
+		{body: `<ns:a xmlns:ns="urn:x" ns:k="a&amp;b"/>`, want: "<ns:a xmlns:ns=\"urn:x\" ns:k=\"a&amp;b\"></ns:a>\n"},
+		{body: "<a><!-- note --><b>x &lt; y</b></a>", want: "<a>\n  <!-- note -->\n  <b>x &lt; y</b>\n</a>\n"},
+		{body: "<a><b>1</b></a>\n\n", want: "<a>\n  <b>1</b>\n</a>\n"},
+		{body: "<a><b></a>", wantErr: true},
+		{body: "<a>", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := indentXML([]byte(tt.body))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("indentXML(%q) error = %v, wantErr %v", tt.body, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && string(got) != tt.want {
+			t.Errorf("indentXML(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{contentType: "application/json", body: `{"beans":[{"State":"active"}]}`, want: "{\n  \"beans\": [\n    {\n      \"State\": \"active\"\n    }\n  ]\n}\n"},
+		{contentType: "application/json; charset=utf-8", body: "{\"a\":1}\n", want: "{\n  \"a\": 1\n}\n"},
+		{contentType: "application/json", body: "  {\"a\":1}\r\n\r\n", want: "{\n  \"a\": 1\n}\n"},
+		{contentType: "text/xml", body: "<conf><name>a</name></conf>\n", want: "<conf>\n  <name>a</name>\n</conf>\n"},
+		// A body that is not valid is written as it came
+		{contentType: "application/json", body: "{\"a\":\n", want: "{\"a\":\n"},
+		{contentType: "text/plain", body: "ok\n", want: "ok\n"},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{
+			Header: http.Header{"Content-Type": {tt.contentType}},
+			Body:   io.NopCloser(strings.NewReader(tt.body)),
+		}
+
+		var out bytes.Buffer
+		if err := formatBody(context.Background(), &out, resp); err != nil {
+			t.Errorf("formatBody(%q) error = %v", tt.body, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("formatBody(%q) = %q, want %q", tt.body, out.String(), tt.want)
+		}
+	}
+}
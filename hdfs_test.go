@@ -0,0 +1,127 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// upload is what the fake DataNode got
+type upload struct {
+	method           string
+	op               string
+	contentLength    int64
+	transferEncoding []string
+	body             []byte
+}
+
+func TestWebHDFSPut(t *testing.T) {
+	defer func(r *hostResolver, overwrite, appending, silent bool) {
+		resolver, hdfsOverwrite, hdfsAppend, silentMode = r, overwrite, appending, silent
+	}(resolver, hdfsOverwrite, hdfsAppend, silentMode)
+
+	r, err := newHostResolver(nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("newHostResolver error = %v", err)
+	}
+	resolver = r
+	silentMode = true
+
+	var mu sync.Mutex
+	var got []upload
+	dataNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A DataNode may send the client to another one, the body is sent again
+		if r.URL.Query().Get("moved") == "" && strings.Contains(r.URL.Path, "moved") {
+			io.Copy(io.Discard, r.Body)
+			http.Redirect(w, r, r.URL.Path+"?"+r.URL.RawQuery+"&moved=1", http.StatusTemporaryRedirect)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("DataNode read error = %v", err)
+		}
+		mu.Lock()
+		got = append(got, upload{r.Method, r.URL.Query().Get("op"), r.ContentLength, r.TransferEncoding, body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer dataNode.Close()
+
+	nameNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > 0 {
+			t.Errorf("the NameNode got a body of %d bytes", r.ContentLength)
+		}
+		location := dataNode.URL + strings.TrimPrefix(r.URL.Path, "/webhdfs/v1") + "?op=" + r.URL.Query().Get("op")
+		http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+	}))
+	defer nameNode.Close()
+
+	data := bytes.Repeat([]byte("gurl\x00\xff"), 100000)
+	tests := []struct {
+		name      string
+		content   []byte
+		hdfsPath  string
+		appending bool
+		want      upload
+	}{
+		{name: "create", content: data, hdfsPath: "/tmp/create", want: upload{method: http.MethodPut, op: "CREATE", contentLength: int64(len(data))}},
+		{name: "append", content: data, hdfsPath: "/tmp/append", appending: true, want: upload{method: http.MethodPost, op: "APPEND", contentLength: int64(len(data))}},
+		{name: "empty file", content: []byte{}, hdfsPath: "/tmp/empty", want: upload{method: http.MethodPut, op: "CREATE", contentLength: 0}},
+		{name: "moved by the DataNode", content: data, hdfsPath: "/tmp/moved", want: upload{method: http.MethodPut, op: "CREATE", contentLength: int64(len(data))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			hdfsAppend = tt.appending
+			localPath := filepath.Join(t.TempDir(), "data")
+			if err := os.WriteFile(localPath, tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := newWebHDFS(context.Background(), nameNode.URL)
+			if err != nil {
+				t.Fatalf("newWebHDFS error = %v", err)
+			}
+			if err := w.put(localPath, tt.hdfsPath); err != nil {
+				t.Fatalf("put(%q) = %v", tt.hdfsPath, err)
+			}
+
+			if len(got) != 1 {
+				t.Fatalf("the DataNode got %d uploads, want 1", len(got))
+			}
+			u := got[0]
+			if u.method != tt.want.method || u.op != tt.want.op {
+				t.Errorf("upload = %s %s, want %s %s", u.method, u.op, tt.want.method, tt.want.op)
+			}
+			if u.contentLength != tt.want.contentLength || len(u.transferEncoding) != 0 {
+				t.Errorf("Content-Length = %d, Transfer-Encoding = %v, want %d and none", u.contentLength, u.transferEncoding, tt.want.contentLength)
+			}
+			if !bytes.Equal(u.body, tt.content) {
+				t.Errorf("the DataNode got %d bytes, want the %d of the file", len(u.body), len(tt.content))
+			}
+		})
+	}
+}
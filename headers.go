@@ -0,0 +1,142 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// header is a single curl style '-H' value
+//
+//	'Name: value'   add the header, replacing any default one
+//	'Name:'         remove the header
+//	'Name;'         send the header with an empty value
+//	'@path'         read the headers from a file, one per line
+type header struct {
+	name   string
+	value  string
+	remove bool
+}
+
+func parseHeaders(specs []string) ([]header, error) {
+	headers := []header{}
+	for _, spec := range specs {
+		if strings.HasPrefix(spec, "@") {
+			fromFile, err := readHeaderFile(strings.TrimPrefix(spec, "@"))
+			if err != nil {
+				return nil, err
+			}
+			headers = append(headers, fromFile...)
+			continue
+		}
+
+		h, err := parseHeader(spec)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, h)
+	}
+
+	return headers, nil
+}
+
+func parseHeader(spec string) (header, error) {
+	spec = strings.TrimSpace(spec)
+
+	if name, found := strings.CutSuffix(spec, ";"); found && !strings.Contains(name, ":") {
+		if !isToken(name) {
+			return header{}, fmt.Errorf("invalid header name in '%s'", spec)
+		}
+		return header{name: name}, nil
+	}
+
+	name, value, found := strings.Cut(spec, ":")
+	name = strings.TrimSpace(name)
+	if !found || !isToken(name) {
+		return header{}, fmt.Errorf("invalid header '%s', expected 'Name: value'", spec)
+	}
+
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, "\r\n") {
+		return header{}, fmt.Errorf("invalid header '%s', value must be a single line", spec)
+	}
+
+	return header{name: name, value: value, remove: value == ""}, nil
+}
+
+func readHeaderFile(path string) ([]header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the headers file: '%s'. Because: %w", path, err)
+	}
+	defer f.Close()
+
+	headers := []header{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		h, err := parseHeader(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s in the headers file: '%s'", err, path)
+		}
+		headers = append(headers, h)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the headers file: '%s'. Because: %w", path, err)
+	}
+
+	return headers, nil
+}
+
+// applyHeaders sets the user supplied headers on the request.
+// The first occurrence of a name replaces what gurl would send by default,
+// the following ones are added next to it.
+func applyHeaders(req *http.Request, headers []header) {
+	seen := map[string]bool{}
+	for _, h := range headers {
+		key := textproto.CanonicalMIMEHeaderKey(h.name)
+
+		if key == "Host" {
+			if !h.remove {
+				req.Host = h.value
+			}
+			continue
+		}
+
+		switch {
+		case h.remove:
+			req.Header.Del(key)
+			// An empty User-Agent stops net/http from sending its own
+			if key == "User-Agent" {
+				req.Header.Set(key, "")
+			}
+		case seen[key]:
+			req.Header.Add(key, h.value)
+		default:
+			req.Header.Set(key, h.value)
+		}
+		seen[key] = !h.remove
+	}
+}
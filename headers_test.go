@@ -0,0 +1,47 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    header
+		wantErr bool
+	}{
+		{spec: "Accept: application/json", want: header{name: "Accept", value: "application/json"}},
+		{spec: "  X-Trace-Id :  a:b:c  ", want: header{name: "X-Trace-Id", value: "a:b:c"}},
+		{spec: "User-Agent:", want: header{name: "User-Agent", remove: true}},
+		{spec: "X-Empty;", want: header{name: "X-Empty"}},
+		{spec: "X-Semi: a;", want: header{name: "X-Semi", value: "a;"}},
+		{spec: "no-colon", wantErr: true},
+		{spec: ": value", wantErr: true},
+		{spec: "Bad Name: value", wantErr: true},
+		{spec: "Bad Name;", wantErr: true},
+		{spec: "X-Split: a\r\nX-Injected: b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseHeader(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHeader(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseHeader(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
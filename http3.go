@@ -0,0 +1,183 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Transport replaces the TCP transport with QUIC for '--http3' & '--http3-only'. The SPNEGO, verbose
+// & failover wrappers are the same, and quic-go reports the connection & TLS events through httptrace.
+func newHTTP3Transport(tlsConfig *tls.Config) *http3.Transport {
+	quicConfig := &quic.Config{}
+	if connectTimeout > 0 {
+		quicConfig.HandshakeIdleTimeout = connectTimeout
+	}
+	if readTimeout > 0 {
+		quicConfig.MaxIdleTimeout = readTimeout
+	}
+
+	return &http3.Transport{
+		TLSClientConfig: tlsConfig.Clone(),
+		QUICConfig:      quicConfig,
+		// Same as the TCP transport, '--compressed' does the decoding
+		DisableCompression: true,
+		Dial:               (&quicDialer{}).dial,
+	}
+}
+
+// quicConnectError is a QUIC connection that could not be made, '--http3' then falls back to TCP
+type quicConnectError struct {
+	err error
+}
+
+func (e *quicConnectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *quicConnectError) Unwrap() error {
+	return e.err
+}
+
+// Timeout keeps the handshake timeouts visible through the url.Error of the client, for their exit code
+func (e *quicConnectError) Timeout() bool {
+	var netErr net.Error
+	return errors.As(e.err, &netErr) && netErr.Timeout()
+}
+
+// http3Fallback sends the requests over QUIC, like curl's '--http3' the hosts QUIC cannot connect to,
+// as UDP is blocked or the server has no HTTP/3, get the same request over TCP and keep using TCP.
+// The 'http://' URLs, like the DataNode redirects of a plain cluster, always go over TCP.
+type http3Fallback struct {
+	h3      http.RoundTripper
+	tcp     http.RoundTripper
+	tcpOnly sync.Map // 'host:port' QUIC could not connect to
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (t *http3Fallback) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, failed := t.tcpOnly.Load(req.URL.Host); failed || req.URL.Scheme != "https" {
+		return t.tcp.RoundTrip(req)
+	}
+
+	resp, err := t.h3.RoundTrip(req)
+	var connectErr *quicConnectError
+	if !errors.As(err, &connectErr) || req.Context().Err() != nil {
+		return resp, err
+	}
+
+	verbosef("* HTTP/3 to %s failed, falling back to TCP. Because: %s\n", req.URL.Host, err)
+	t.tcpOnly.Store(req.URL.Host, true)
+
+	// The body is only read once the QUIC connection is up, a replayable one is sent fresh anyway
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.tcp.RoundTrip(req)
+}
+
+// quicDialer is the dial of quic-go with the '--connect-to', '--resolve' & DNS overrides,
+// every connection shares one UDP socket
+type quicDialer struct {
+	once      sync.Once
+	transport *quic.Transport
+	err       error
+}
+
+func (d *quicDialer) dial(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	d.once.Do(func() {
+		d.err = bindLocalPort(func(port int) error {
+			conn, err := net.ListenUDP(ipNetwork("udp"), &net.UDPAddr{IP: localIP, Port: port})
+			if err == nil {
+				d.transport = &quic.Transport{Conn: conn}
+			}
+			return err
+		})
+		if d.err == nil && (localIP != nil || localPortFrom > 0) {
+			verbosef("* Local address %s\n", d.transport.Conn.LocalAddr())
+		}
+	})
+	if d.err != nil {
+		return nil, &quicConnectError{err: d.err}
+	}
+
+	host, port, addrs, err := resolver.route(addr)
+	if err != nil {
+		return nil, err
+	}
+	if addrs == nil {
+		if addrs, err = resolver.lookup(ctx, host, port); err != nil {
+			return nil, err
+		}
+	}
+
+	remote := ""
+	for _, a := range addrs {
+		if isInFamily(a) {
+			remote = a
+			break
+		}
+	}
+	if remote == "" {
+		return nil, fmt.Errorf("no address of '%s' matches the address family", host)
+	}
+
+	udpAddr, err := net.ResolveUDPAddr(ipNetwork("udp"), net.JoinHostPort(remote, port))
+	if err != nil {
+		return nil, err
+	}
+
+	// The same events as a TCP dial, for '-v' & '-w'. QUIC has no connect before its handshake,
+	// the connection is done when the handshake starts so 'time_connect' stays before 'time_appconnect'.
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("udp", udpAddr.String())
+	}
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("udp", udpAddr.String(), nil)
+	}
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+
+	conn, err := d.transport.DialEarly(ctx, udpAddr, tlsCfg, cfg)
+
+	var state tls.ConnectionState
+	if conn != nil {
+		state = conn.ConnectionState().TLS
+	}
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(state, err)
+	}
+	if err != nil {
+		return nil, &quicConnectError{err: err}
+	}
+	return conn, nil
+}
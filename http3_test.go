@@ -0,0 +1,96 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHTTP3Transport(t *testing.T) {
+	defer func(mode, only bool, timeout time.Duration, r *hostResolver) {
+		http3Mode, http3Only, connectTimeout, resolver = mode, only, timeout, r
+	}(http3Mode, http3Only, connectTimeout, resolver)
+
+	r, err := newHostResolver(nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("newHostResolver error = %v", err)
+	}
+	resolver, connectTimeout = r, time.Second
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+
+	tests := []struct {
+		name      string
+		only      bool
+		quic      bool
+		wantProto string
+		wantErr   bool
+	}{
+		{name: "http3", quic: true, wantProto: "HTTP/3.0"},
+		{name: "http3-only", only: true, quic: true, wantProto: "HTTP/3.0"},
+		{name: "fallback when UDP is refused", quic: false, wantProto: "HTTP/2.0"},
+		{name: "http3-only when UDP is refused", only: true, quic: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		// The TCP & the QUIC servers share the port and the self-signed certificate of httptest
+		tcp := httptest.NewUnstartedServer(handler)
+		tcp.EnableHTTP2 = true
+		tcp.StartTLS()
+
+		if tt.quic {
+			udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: tcp.Listener.Addr().(*net.TCPAddr).Port})
+			if err != nil {
+				t.Fatalf("%s: unable to listen on UDP. Because: %v", tt.name, err)
+			}
+			h3 := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tcp.TLS.Certificates})}
+			go h3.Serve(udp)
+			defer func() {
+				h3.Close()
+				udp.Close()
+			}()
+		}
+
+		http3Mode, http3Only = true, tt.only
+		resp, err := newClient().Get(tcp.URL)
+		var body []byte
+		if err == nil {
+			body, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		tcp.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr && exitCode(err) != exitTimeout {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, exitCode(err), exitTimeout)
+		}
+		if !tt.wantErr && string(body) != tt.wantProto {
+			t.Errorf("%s: protocol = %q, want %q", tt.name, body, tt.wantProto)
+		}
+	}
+}
@@ -16,33 +16,52 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
 	"time"
 )
 
-func doKinit(keytabPath, kerberosPrinciple string) error {
-	// Try Kinit for the current user
-	tryKinit := exec.Command(defaultShell, "-c", "kinit", "-kt", keytabPath, kerberosPrinciple)
+func doKinit(ctx context.Context, keytabPath, kerberosPrinciple string) error {
+	if kdcTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, kdcTimeout)
+		defer cancel()
+	}
+
+	// Try Kinit for the current user.
+	// kinit is run directly: with 'sh -c kinit -kt ...' the keytab & the principal were the arguments
+	// of the shell and not of kinit, and the timeout would only kill the shell.
+	tryKinit := exec.CommandContext(ctx, "kinit", "-kt", keytabPath, kerberosPrinciple)
+	tryKinit.WaitDelay = time.Second
 	var outb, errb bytes.Buffer
 	tryKinit.Stdout = &outb
 	tryKinit.Stderr = &errb
 	if err := tryKinit.Run(); err != nil {
-		return fmt.Errorf(errb.String() + err.Error())
+		if ctx.Err() != nil {
+			return fmt.Errorf("kinit did not finish in time. Because: %w", ctx.Err())
+		}
+		return errors.New(errb.String() + err.Error())
 	}
 	return nil
 }
 
-func isKerberosCacheValid(timestampLayout string) (bool, error) {
+func isKerberosCacheValid(ctx context.Context, timestampLayout string) (bool, error) {
 	//
 	var outb, errb bytes.Buffer
 	currentDate := time.Now()
-	tryKlist := exec.Command(defaultShell, "-c", "klist | awk '{print $3}' | grep '^[0-9]' | head -1")
+	tryKlist := exec.CommandContext(ctx, defaultShell, "-c", "klist | awk '{print $3}' | grep '^[0-9]' | head -1")
+	// Cancelling only kills the shell, klist & the pipe may still hold its output open
+	tryKlist.WaitDelay = time.Second
 	tryKlist.Stdout = &outb
 	tryKlist.Stderr = &errb
 	if err := tryKlist.Run(); err != nil {
-		return false, fmt.Errorf(errb.String() + err.Error())
+		if ctx.Err() != nil {
+			return false, fmt.Errorf("klist did not finish in time. Because: %w", ctx.Err())
+		}
+		return false, errors.New(errb.String() + err.Error())
 	}
 	expiryDateString := strings.TrimSpace(outb.String())
 	if expiryDateString == "" {
@@ -65,7 +84,7 @@ func isKerberosCacheValid(timestampLayout string) (bool, error) {
 	}
 
 	if expiryDate.IsZero() {
-		fmt.Printf("ERROR: expiry date'%s' matches NONE of supported timestamp layout\n", expiryDate)
+		logErrorf("ERROR: expiry date'%s' matches NONE of supported timestamp layout\n", expiryDate)
 		return false, err
 	}
 
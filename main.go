@@ -15,22 +15,32 @@
 package main
 
 import (
-	"fmt"
+	"crypto/x509"
+	"net"
 	netURL "net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/integrii/flaggy"
 )
 
 // Configurations
 // NOTE: All these below configutaions are global scoped
-// DO NOT MUTATE them any where in the program
+// They are only set while parseFlags() reads and validates the flags,
+// DO NOT MUTATE them any where else in the program
 var (
 	Version                   = "0.0.0"
 	BuildID                   = "0"
 	url                       = ""
-	reqType                   = "GET"
+	baseURL                   = ""
+	urls                      = []string{}
+	urlFile                   = ""
+	globOff                   = false
+	parallelMode              = false
+	parallelMax               = 50
+	transfers                 []transfer
+	reqType                   = ""
 	isKerberized              = false
 	keytabPath                = "/etc/security/hdfs-headless.keytab"
 	kerberosPrinciple         = "hdfs@ACME.ORG"
@@ -41,6 +51,75 @@ var (
 	outputFile                = ""
 	clientUserAgent           = "gurl/0.0.1"
 	enforceTLSVerify          = false
+	caCertFile                = ""
+	forceHTTP11               = false
+	forceHTTP2                = false
+	http2PriorKnowledge       = false
+	http3Mode                 = false
+	http3Only                 = false
+	unixSocket                = ""
+	resolveEntries            = []string{}
+	connectToEntries          = []string{}
+	hostsFile                 = ""
+	dnsServers                = ""
+	resolver                  *hostResolver
+	ipv4Only                  = false
+	ipv6Only                  = false
+	interfaceName             = ""
+	localAddress              = ""
+	localPort                 = ""
+	localIP                   net.IP
+	localPortFrom             = 0
+	localPortTo               = 0
+	compressedMode            = false
+	rawMode                   = false
+	gzipRequestBody           = false
+	failOnError               = false
+	failWithBody              = false
+	jsonResultFile            = ""
+	jqQuery                   = ""
+	jqRawOutput               = false
+	prettyMode                = false
+	limitRateSpec             = ""
+	limitRate                 int64
+	progressMode              = false
+	caCertPool                *x509.CertPool
+	formFields                = []string{}
+	requestHeaders            = []string{}
+	maxRedirects              = 10
+	locationTrusted           = false
+	showRedirects             = false
+	hdfsSiteFile              = "/etc/hadoop/conf/hdfs-site.xml"
+	haStateFile               = ""
+	haEndpoints               []*netURL.URL
+	includeHeaders            = false
+	headOnly                  = false
+	dumpHeaderFile            = ""
+	silentMode                = false
+	showErrors                = false
+	writeOutFormat            = ""
+	verboseMode               = false
+	traceFile                 = ""
+	traceASCIIFile            = ""
+	noRedact                  = false
+	retryCount                = 0
+	retryBaseDelay            = time.Second
+	retryMaxTime              time.Duration
+	retryOn                   = "408,429,502,503,504"
+	retryStatuses             []int
+	retryNonIdempotent        = false
+	connectTimeout            = 30 * time.Second
+	maxTime                   time.Duration
+	readTimeout               time.Duration
+	kdcTimeout                = 30 * time.Second
+	resumeFrom                = ""
+	resumeDownload            = false
+	createDirs                = false
+	removeOnError             = false
+	proxyAddr                 = ""
+	proxyUser                 = ""
+	proxyNegotiate            = false
+	noProxy                   = ""
 	reqHTTPMethod             httpMethod
 	availableTimestampLayouts = []string{"01/02/2006", "01/02/06", "02/01/2006", "02/01/06", "2006/01/02", "06/01/02", "2006/02/01", "06/02/01"}
 	defaultShell              = "/usr/bin/sh"
@@ -48,7 +127,9 @@ var (
 	secondaryKRBConfig        = "/etc/krb5/krb5.conf"
 )
 
-func init() {
+// parseFlags reads the flags, the profiles & the 'GURL_*' variables into the globals and validates them.
+// It is called by main and not from init, so the tests of the package do not parse their own arguments.
+func parseFlags() {
 	flaggy.SetName("gURL")
 	flaggy.SetDescription("gURL - A replacement for statically compiled cURL binary")
 
@@ -64,9 +145,14 @@ Example: "gurl -X GET -ua "gurl/0.0.1" -u "hdfs:" -k -kt /etc/security/hdfs-head
 	flaggy.SetVersion(version)
 
 	//
-	flaggy.String(&url, "l", "url", "URL to make request")
+	flaggy.StringSlice(&urls, "l", "url", "URL to make request, repeat it for several URLs. HA endpoints can be listed as 'http://nn1:9870/jmx,http://nn2:9870' or given as an HDFS nameservice 'http://mycluster/jmx'")
+	flaggy.String(&baseURL, "", "base-url", "Base URL the '-l' paths like '/jmx' are relative to, usually set by a profile. It can be an HA list")
+	flaggy.String(&urlFile, "", "url-file", "File with one URL per line to make requests to, '-' for stdin")
+	flaggy.Bool(&globOff, "g", "globoff", "Do not expand the '[01-40]' & '{nn1,nn2}' URL patterns")
+	flaggy.Bool(&parallelMode, "Z", "parallel", "Make the requests to several URLs in parallel")
+	flaggy.Int(&parallelMax, "", "parallel-max", "Maximum number of requests running at once with '--parallel'")
 
-	flaggy.String(&reqType, "X", "type", "HTTP request type to use")
+	flaggy.String(&reqType, "X", "type", "HTTP request type to use (default: GET, or POST when a form is given)")
 
 	flaggy.Bool(&isKerberized, "k", "kerberized", "Is Kerberos enabled for the URL")
 	flaggy.String(&keytabPath, "kt", "keytab-path", "Kerberos Keytab Path")
@@ -77,31 +163,295 @@ Example: "gurl -X GET -ua "gurl/0.0.1" -u "hdfs:" -k -kt /etc/security/hdfs-head
 	flaggy.String(&isBasicAuth, "u", "basic-auth", "Is Basic Auth Enabled for the URL")
 
 	flaggy.Bool(&enforceTLSVerify, "ev", "enforce-tls-verify", "Enforce TLS certification verification")
+	flaggy.Bool(&forceHTTP11, "", "http1.1", "Use HTTP/1.1 only (default)")
+	flaggy.Bool(&forceHTTP2, "", "http2", "Use HTTP/2 when the TLS server offers it with ALPN, HTTP/1.1 otherwise")
+	flaggy.Bool(&http2PriorKnowledge, "", "http2-prior-knowledge", "Use HTTP/2 without asking first, unencrypted (h2c) for 'http://' URLs")
+	flaggy.Bool(&http3Mode, "", "http3", "Use HTTP/3 over QUIC for the 'https://' URLs, with HTTP/2 or HTTP/1.1 over TCP for the hosts QUIC cannot connect to. The proxies are not used")
+	flaggy.Bool(&http3Only, "", "http3-only", "Use HTTP/3 over QUIC without falling back to TCP, only for 'https://' URLs")
+	flaggy.String(&unixSocket, "", "unix-socket", "Connect to this unix socket instead of the URL host, the URL still gives the Host header, the SPN & the TLS server name")
+	flaggy.StringSlice(&resolveEntries, "", "resolve", "Use these addresses for host:port instead of the DNS, repeat it for several hosts. Example: 'nn.acme.org:9871:10.0.0.5', '*' matches any port")
+	flaggy.StringSlice(&connectToEntries, "", "connect-to", "Connect to another host:port for host:port, the URL still gives the Host header & the TLS server name. Example: 'nn.acme.org:9871:nn02.acme.org:9871'")
+	flaggy.String(&hostsFile, "", "hosts-file", "File in the '/etc/hosts' format to resolve the hosts with before the DNS")
+	flaggy.String(&dnsServers, "", "dns-servers", "Comma separated DNS servers to use instead of the system ones. Example: '10.0.0.2,10.0.0.3:5353'")
+	flaggy.Bool(&ipv4Only, "4", "ipv4", "Only connect to the IPv4 addresses of the hosts")
+	flaggy.Bool(&ipv6Only, "6", "ipv6", "Only connect to the IPv6 addresses of the hosts")
+	flaggy.String(&interfaceName, "", "interface", "Connect from the address of this network interface. Example: 'eth1'")
+	flaggy.String(&localAddress, "", "local-address", "Connect from this local IP address")
+	flaggy.String(&localPort, "", "local-port", "Connect from this local port or the first free one of a range. Example: '40000-40100'")
+	flaggy.String(&caCertFile, "", "cacert", "PEM file of the CA certificates to verify the server with, instead of the system ones")
 
 	flaggy.String(&clientUserAgent, "ua", "user-agent", "User Agent to be set for the client requests")
-	flaggy.String(&outputFile, "o", "output-file", "Write the request response to a file")
+	flaggy.String(&outputFile, "o", "output-file", "Write the request response to a file, '#1', '#2'... are replaced by the values of the URL globs. Example: 'jmx-#1.json'")
+	flaggy.String(&resumeFrom, "C", "continue-at", "Use '-' to continue an interrupted '-o' download from its '<file>.part', if the file did not change on the server")
+	flaggy.Bool(&createDirs, "", "create-dirs", "Create the missing directories of the '-o' path")
+	flaggy.Bool(&removeOnError, "", "remove-on-error", "Remove the partial download on errors instead of keeping it for '-C -'")
+	flaggy.Bool(&compressedMode, "", "compressed", "Ask for a gzip, deflate, br or zstd compressed response and decode it")
+	flaggy.Bool(&rawMode, "", "raw", "Do not decode the compressed responses, the encoded bytes are written as-is")
+	flaggy.String(&limitRateSpec, "", "limit-rate", "Maximum upload & download rate in bytes per second. Example: '500K', '2M' or '1G'")
+	flaggy.Bool(&progressMode, "#", "progress-bar", "Show a progress bar on stderr, it is shown when stdout is a terminal unless the body is written to it")
+	flaggy.Bool(&failOnError, "f", "fail", "Exit with 22 on the HTTP errors (400 & above) without writing the body")
+	flaggy.Bool(&failWithBody, "", "fail-with-body", "Exit with 22 on the HTTP errors (400 & above), the body is still written")
+	flaggy.Bool(&includeHeaders, "i", "include", "Print the status line and the response headers before the body")
+	flaggy.Bool(&headOnly, "I", "head", "Make a HEAD request and print the status line and the response headers")
+	flaggy.String(&dumpHeaderFile, "D", "dump-header", "Write the status line and the response headers to a file, '-' for stdout")
+	flaggy.Bool(&silentMode, "s", "silent", "Do not print any message, only the response")
+	flaggy.Bool(&showErrors, "S", "show-error", "Print the errors even with '-s'")
+	flaggy.Bool(&verboseMode, "v", "verbose", "Print the requests, the responses, the TLS handshakes, the redirects and the SPNEGO steps to stderr")
+	flaggy.String(&traceFile, "", "trace", "Write a hex dump of the bytes sent & received to a file, '-' for stdout. HTTP/2 needs '--no-redact'")
+	flaggy.String(&traceASCIIFile, "", "trace-ascii", "Write the bytes sent & received as text to a file, '-' for stdout. HTTP/2 needs '--no-redact'")
+	flaggy.Bool(&noRedact, "", "no-redact", "Show the Authorization, Cookie & password values in the verbose & trace output")
+	flaggy.String(&jqQuery, "", "jq", "Filter the JSON body with a jq query, like 'jq' would print it. Example: '.beans[0].State'")
+	flaggy.Bool(&jqRawOutput, "", "raw-output", "Print the strings of the 'jq' results without the quotes, like 'jq -r'")
+	flaggy.Bool(&prettyMode, "", "pretty", "Indent the JSON & XML bodies, going by their Content-Type")
+	flaggy.String(&jsonResultFile, "", "json-result", "Write a JSON record of every request to a file, '-' for stdout. The body is in the record instead of stdout")
+	flaggy.String(&writeOutFormat, "w", "write-out", "Print the cURL style format after the transfer, '@path' reads it from a file. Example: '%{http_code} %{time_spnego} %{time_total}\\n'")
+	flaggy.StringSlice(&requestHeaders, "H", "header", "Add a request header. 'Name: value' to set, 'Name:' to remove, 'Name;' to send it empty or '@path' to read them from a file")
+
+	flaggy.Int(&maxRedirects, "mr", "max-redirs", "Maximum number of redirects to follow, 0 disables following them")
+	flaggy.Bool(&locationTrusted, "lt", "location-trusted", "Send the credentials to every host in the redirect chain, not only to the origin")
+	flaggy.Bool(&showRedirects, "sr", "show-redirects", "Print the redirect chain to stderr")
+
+	flaggy.String(&hdfsSiteFile, "", "hdfs-site", "hdfs-site.xml used to resolve an HA nameservice in the URL")
+	flaggy.String(&haStateFile, "", "ha-state-file", "File remembering the last active HA endpoint (default: <user-cache-dir>/gurl/ha-state.json)")
+
+	flaggy.String(&proxyAddr, "x", "proxy", "Proxy to use: 'http://host:port', 'https://host:port', 'socks5://host:port' or 'socks5h://host:port'. Defaults to the HTTP(S)_PROXY variables")
+	flaggy.String(&proxyUser, "U", "proxy-user", "Basic auth for the proxy as 'username:password'")
+	flaggy.Bool(&proxyNegotiate, "", "proxy-negotiate", "Answer the Negotiate challenges of the proxy with a Kerberos token for 'HTTP/<proxy-host>'")
+	flaggy.String(&noProxy, "", "noproxy", "Comma separated hosts & domains to reach without the proxy, overrides NO_PROXY")
+
+	flaggy.Duration(&connectTimeout, "", "connect-timeout", "Maximum time for the TCP connection & the TLS handshake, 0 disables it")
+	flaggy.Duration(&maxTime, "m", "max-time", "Maximum time for the whole run, Kerberos exchanges & retries included. Example: '90s'")
+	flaggy.Duration(&readTimeout, "", "read-timeout", "Fail when no data is received for this long. Example: '30s'")
+	flaggy.Duration(&kdcTimeout, "", "kdc-timeout", "Maximum time for kinit & for getting a service ticket from the KDC, 0 disables it")
+
+	flaggy.Int(&retryCount, "", "retry", "Retry the request N times on connection errors, timeouts & the 'retry-on' status codes")
+	flaggy.Duration(&retryBaseDelay, "", "retry-delay", "Initial delay between the retries, it doubles on every retry unless the server sends Retry-After")
+	flaggy.Duration(&retryMaxTime, "", "retry-max-time", "Do not retry once this much time has passed since the first attempt. Example: '2m'")
+	flaggy.String(&retryOn, "", "retry-on", "Comma separated status codes to retry on")
+	flaggy.Bool(&retryNonIdempotent, "", "retry-non-idempotent", "Also retry the methods that are not idempotent, like POST & PATCH")
+
+	flaggy.StringSlice(&formFields, "F", "form", "Add a multipart form field. Example: 'name=value', 'file=@path;type=application/java-archive' or 'conf=<path'")
+	flaggy.Bool(&gzipRequestBody, "", "gzip-body", "Compress the request body with gzip and send it with 'Content-Encoding: gzip'")
+
+	registerHDFSCommands()
+	registerConfigCommands()
 
 	flaggy.Parse()
 
+	if err := loadSettings(systemConfigFile, userConfigPath()); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	}
+
+	// 'config show' only prints the settings, nothing is required for it
+	if configCmd.Used {
+		return
+	}
+
 	// Trim Extra Space from all user inputs
-	url = strings.TrimSpace(url)
+	for i := range urls {
+		urls[i] = strings.TrimSpace(urls[i])
+	}
+	urls = removeFromSlice("", urls)
+	if len(urls) == 0 && urlFile == "" && baseURL != "" {
+		urls = []string{baseURL}
+	}
+	urlFile = strings.TrimSpace(urlFile)
 	reqType = strings.TrimSpace(reqType)
 	clientUserAgent = strings.TrimSpace(clientUserAgent)
 	isBasicAuth = strings.TrimSpace(isBasicAuth)
 
 	// Args validation & manipulation
-	if url == "" {
-		flaggy.ShowHelpAndExit("ERROR: 'url' parameter is required")
+	if headOnly {
+		reqType = "HEAD"
+	}
+
+	if reqType == "" {
+		reqType = "GET"
+		if len(formFields) > 0 {
+			reqType = "POST"
+		}
+	}
+
+	if m, err := stringToMethod(reqType); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	} else {
+		reqHTTPMethod = m
+	}
+
+	if maxRedirects < 0 {
+		flaggy.ShowHelpAndExit("ERROR: 'max-redirs' cannot be negative")
+	}
+
+	// The HTTP/2 headers are HPACK encoded, the trace cannot find the credentials in them
+	if (traceFile != "" || traceASCIIFile != "") && (forceHTTP2 || http2PriorKnowledge) && !noRedact {
+		flaggy.ShowHelpAndExit("ERROR: 'trace' cannot redact the HTTP/2 headers, add 'no-redact' to dump them as they are")
+	}
+
+	if traceFile != "" {
+		if err := openWireTrace(traceFile, false); err != nil {
+			flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+		}
+	} else if traceASCIIFile != "" {
+		if err := openWireTrace(traceASCIIFile, true); err != nil {
+			flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+		}
+	}
+
+	switch strings.TrimSpace(resumeFrom) {
+	case "":
+	case "-":
+		resumeDownload = true
+	default:
+		flaggy.ShowHelpAndExit("ERROR: 'continue-at' parameter only accepts '-', the offset is taken from the partial download")
+	}
+
+	if resumeDownload && outputFile == "" && !hdfsCmd.Used {
+		flaggy.ShowHelpAndExit("ERROR: 'continue-at' parameter needs an 'output-file'")
+	}
+
+	if addr, err := validateProxy(strings.TrimSpace(proxyAddr)); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: 'proxy' parameter is invalid. " + err.Error())
+	} else {
+		proxyAddr = addr
+	}
+
+	if connectTimeout < 0 || maxTime < 0 || readTimeout < 0 || kdcTimeout < 0 {
+		flaggy.ShowHelpAndExit("ERROR: the timeouts cannot be negative")
+	}
+
+	if retryCount < 0 || retryBaseDelay < 0 || retryMaxTime < 0 {
+		flaggy.ShowHelpAndExit("ERROR: 'retry', 'retry-delay' & 'retry-max-time' cannot be negative")
+	}
+
+	if codes, err := parseRetryStatuses(retryOn); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	} else {
+		retryStatuses = codes
+	}
+
+	if format, err := loadWriteOut(writeOutFormat); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	} else {
+		writeOutFormat = format
+	}
+
+	if _, err := parseHeaders(requestHeaders); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	}
+
+	for _, field := range formFields {
+		if _, err := parseFormPart(field); err != nil {
+			flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+		}
+	}
+
+	if countTrue(forceHTTP11, forceHTTP2, http2PriorKnowledge, http3Mode, http3Only) > 1 {
+		flaggy.ShowHelpAndExit("ERROR: only one of 'http1.1', 'http2', 'http2-prior-knowledge', 'http3' & 'http3-only' can be used")
+	}
+
+	if (http3Mode || http3Only) && (proxyAddr != "" || tracer != nil) {
+		flaggy.ShowHelpAndExit("ERROR: 'http3' cannot go through a 'proxy' and has no 'trace' of the QUIC packets")
+	}
+
+	if r, err := newHostResolver(resolveEntries, connectToEntries, hostsFile, dnsServers); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	} else {
+		resolver = r
+	}
+
+	if rate, err := parseRate(limitRateSpec); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	} else if rate > 0 {
+		limitRate, transferLimiter = rate, newRateLimiter(rate)
+	}
+
+	if jsonResultFile != "" {
+		if includeHeaders || writeOutFormat != "" || hdfsCmd.Used {
+			flaggy.ShowHelpAndExit("ERROR: 'json-result' has the headers & the timings, it cannot be used with 'include', 'write-out' or the hdfs commands")
+		}
+		if err := openJSONResult(jsonResultFile); err != nil {
+			flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+		}
+	}
+
+	if jqQuery != "" || prettyMode {
+		if outputFile != "" || hdfsCmd.Used {
+			flaggy.ShowHelpAndExit("ERROR: 'jq' & 'pretty' format the body written to stdout, they cannot be used with 'output-file' or the hdfs commands")
+		}
+	}
+
+	if jqQuery != "" {
+		code, err := compileJQ(jqQuery)
+		if err != nil {
+			flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+		}
+		jqCode = code
+	}
+
+	if failOnError && failWithBody {
+		flaggy.ShowHelpAndExit("ERROR: only one of 'fail' & 'fail-with-body' can be used")
+	}
+
+	if ipv4Only && ipv6Only {
+		flaggy.ShowHelpAndExit("ERROR: only one of 'ipv4' & 'ipv6' can be used")
+	}
+
+	if interfaceName != "" && localAddress != "" {
+		flaggy.ShowHelpAndExit("ERROR: only one of 'interface' & 'local-address' can be used")
+	}
+
+	if ip, err := parseLocalAddress(interfaceName, localAddress); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
 	} else {
-		_, err := netURL.Parse(url)
+		localIP = ip
+	}
+
+	if from, to, err := parseLocalPorts(localPort); err != nil {
+		flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+	} else {
+		localPortFrom, localPortTo = from, to
+	}
+
+	if unixSocket != "" {
+		if http3Mode || http3Only || proxyAddr != "" || proxyNegotiate {
+			flaggy.ShowHelpAndExit("ERROR: 'unix-socket' cannot be used with 'http3' or a 'proxy'")
+		}
+		if err := validateUnixSocket(unixSocket); err != nil {
+			flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+		}
+	}
+
+	if caCertFile != "" {
+		pool, err := loadCACerts(caCertFile)
 		if err != nil {
-			fmt.Println("ERROR: ", err.Error())
-			flaggy.ShowHelpAndExit("ERROR: 'url' parameter has a invalid url")
+			flaggy.ShowHelpAndExit("ERROR: " + err.Error())
+		}
+		caCertPool = pool
+	}
 
+	if parallelMax < 1 {
+		flaggy.ShowHelpAndExit("ERROR: 'parallel-max' must be at least 1")
+	}
+
+	if len(urls) == 0 && urlFile == "" {
+		flaggy.ShowHelpAndExit("ERROR: 'url' parameter is required")
+	} else {
+		var err error
+		transfers, err = collectTransfers(urls, urlFile, outputFile, hdfsSiteFile)
+		if err != nil {
+			flaggy.ShowHelp("ERROR: 'url' parameter is invalid. " + err.Error())
+			os.Exit(exitMalformedURL)
 		}
+
+		// The single transfer & the hdfs commands keep using the first URL as before
+		url, haEndpoints = transfers[0].url, transfers[0].endpoints
+	}
+
+	if hdfsCmd.Used && len(transfers) > 1 {
+		flaggy.ShowHelpAndExit("ERROR: the hdfs commands take a single 'url'")
 	}
 
-	if isKerberized {
+	if isKerberized || proxyNegotiate {
 		//
 		keytabPath = strings.TrimSpace(keytabPath)
 		kerberosPrinciple = strings.TrimSpace(kerberosPrinciple)
@@ -121,7 +471,7 @@ Example: "gurl -X GET -ua "gurl/0.0.1" -u "hdfs:" -k -kt /etc/security/hdfs-head
 
 		if timestampLayout != "" {
 			if !isInSlice(timestampLayout, availableTimestampLayouts) {
-				fmt.Printf("WARN: '%s' is not in the default 'ts-format' values\n", timestampLayout)
+				logf("WARN: '%s' is not in the default 'ts-format' values\n", timestampLayout)
 			} else {
 				availableTimestampLayouts = removeFromSlice(timestampLayout, availableTimestampLayouts)
 			}
@@ -156,31 +506,63 @@ func main() {
 	// Incase of different location set it @ env 'KRB5CCNAME'
 	// ------- NOTE ---------------
 
-	// Check if kerberos is enabled
-	if isKerberized {
-		isKerberosCacheValid, err := isKerberosCacheValid(timestampLayout)
-		if err != nil {
-			fmt.Println("ERROR: Unable to validate Kerberos cache. Because: ", err.Error())
+	parseFlags()
+
+	if configCmd.Used {
+		if !configShowCmd.Used {
+			logErrorf("ERROR: missing the config operation, run 'gurl config --help' for the list\n")
+			os.Exit(1)
+		}
+		if err := showConfig(os.Stdout); err != nil {
+			logErrorf("ERROR: %s\n", err)
 			os.Exit(1)
 		}
+		return
+	}
+
+	ctx, cancel := newRunContext()
+	defer cancel()
+
+	// Check if kerberos is enabled, for the origin or the proxy
+	if isKerberized || proxyNegotiate {
+		isKerberosCacheValid, err := isKerberosCacheValid(ctx, timestampLayout)
+		if err != nil {
+			logErrorf("ERROR: Unable to validate Kerberos cache. Because: %s\n", err)
+			os.Exit(exitKerberos)
+		}
 
 		if !isKerberosCacheValid {
-			if err := doKinit(keytabPath, kerberosPrinciple); err != nil {
-				fmt.Println("ERROR: Unable to do Kinit. Because: ", err.Error())
-				os.Exit(1)
+			verbosef("* Kerberos cache is not valid, running kinit with '%s' for '%s'\n", keytabPath, kerberosPrinciple)
+			if err := doKinit(ctx, keytabPath, kerberosPrinciple); err != nil {
+				logErrorf("ERROR: Unable to do Kinit. Because: %s\n", err)
+				os.Exit(exitKerberos)
 			}
 		}
 	}
 
-	if reqHTTPMethod, err := stringToMethod(reqType); err == nil {
-		_, n, err := makeRequest(reqHTTPMethod, url)
-		if err != nil {
-			fmt.Println("STATUS: ", n)
-			fmt.Println("ERROR: ", err)
-			os.Exit(1)
+	if hdfsCmd.Used {
+		if err := runHDFS(ctx); err != nil {
+			logErrorf("ERROR: %s\n", err)
+			os.Exit(exitCode(err))
 		}
-	} else {
-		fmt.Println("ERROR:", err.Error())
-		os.Exit(1)
+		return
+	}
+
+	if len(transfers) > 1 {
+		if err := runTransfers(ctx, reqHTTPMethod, transfers); err != nil {
+			logErrorf("ERROR: %s\n", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	_, n, err := makeRequest(ctx, newClient(), reqHTTPMethod, transfers[0], os.Stdout)
+	if err != nil {
+		// No status when the request failed before any response, like on a local file error
+		if n != 0 {
+			logErrorf("STATUS: %d\n", n)
+		}
+		logErrorf("ERROR: %s\n", err)
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,118 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// logf prints gurl's own messages on stderr, stdout only ever carries the response
+func logf(format string, a ...interface{}) {
+	if !silentMode {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
+
+// logErrorf prints the errors, they are muted by '-s' unless '-S' is given too
+func logErrorf(format string, a ...interface{}) {
+	if !silentMode || showErrors {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
+
+// writeHeaders writes the status line and the headers in the wire format. Go keeps no order
+// between the header names, so they are sorted, the values of a name keep their order
+func writeHeaders(w io.Writer, resp *http.Response) error {
+	if _, err := fmt.Fprintf(w, "%s %s\r\n", resp.Proto, resp.Status); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range resp.Header[k] {
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+func dumpHeaders(w io.Writer, path string, resp *http.Response) error {
+	if path == "-" {
+		return writeHeaders(w, resp)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create the header dump file at: '%s'. Because: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := writeHeaders(f, resp); err != nil {
+		return fmt.Errorf("unable to write the header dump file at: '%s'. Because: %w", path, err)
+	}
+	return nil
+}
+
+// writeResponse sends the response where the output flags ask for it.
+// The body is streamed unchanged to w, successful responses go to the '-o' download when it is set.
+func writeResponse(w io.Writer, resp *http.Response, out *download) error {
+	if dumpHeaderFile != "" {
+		if err := dumpHeaders(w, dumpHeaderFile, resp); err != nil {
+			return asWriteError(err)
+		}
+	}
+
+	if includeHeaders || headOnly {
+		if err := writeHeaders(w, resp); err != nil {
+			return asWriteError(err)
+		}
+	}
+
+	if headOnly {
+		return nil
+	}
+
+	// A 416 to a resumed download means it was already complete
+	if out != nil && (resp.StatusCode <= 299 || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && out.offset > 0) {
+		if err := out.saveResponse(resp); err != nil {
+			return err
+		}
+		logf("INFO: Output redirected the file: '%s'\n", out.path)
+		return nil
+	}
+
+	// 'jq' & 'pretty' are refused with '-o' when parsing the flags, only stdout is formatted
+	if jqCode != nil || prettyMode {
+		return formatBody(resp.Request.Context(), w, resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return asWriteError(fmt.Errorf("unable to read the response body. Because: %w", err))
+	}
+	return nil
+}
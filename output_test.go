@@ -0,0 +1,123 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputModes(t *testing.T) {
+	// Binary, with no final newline
+	body := "\x00\x1f\x8b\xff\r\nbinary"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not here", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	dump := filepath.Join(t.TempDir(), "headers.txt")
+	tests := []struct {
+		name       string
+		args       []string
+		wantStdout func(string) bool
+		wantStderr string
+		wantDump   bool
+		wantCode   int
+	}{
+		{
+			name:       "body only",
+			args:       []string{"-l", srv.URL},
+			wantStdout: func(out string) bool { return out == body },
+		},
+		{
+			name: "include headers",
+			args: []string{"-i", "-l", srv.URL},
+			wantStdout: func(out string) bool {
+				return strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n") && strings.Contains(out, "\r\nX-Method: GET\r\n") && strings.HasSuffix(out, "\r\n\r\n"+body)
+			},
+		},
+		{
+			name: "head",
+			args: []string{"-I", "-l", srv.URL},
+			wantStdout: func(out string) bool {
+				return strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n") && strings.Contains(out, "\r\nX-Method: HEAD\r\n") && strings.HasSuffix(out, "\r\n\r\n")
+			},
+		},
+		{
+			name:       "dump headers to a file",
+			args:       []string{"-D", dump, "-l", srv.URL},
+			wantStdout: func(out string) bool { return out == body },
+			wantDump:   true,
+		},
+		{
+			name: "dump headers to stdout",
+			args: []string{"-D", "-", "-l", srv.URL},
+			wantStdout: func(out string) bool {
+				return strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n") && strings.HasSuffix(out, "\r\n\r\n"+body)
+			},
+		},
+		{
+			name:       "error status",
+			args:       []string{"-l", srv.URL + "/missing"},
+			wantStdout: func(out string) bool { return out == "not here\n" },
+			wantStderr: "ERROR: Server returned status: 404 Not Found\n",
+		},
+		{
+			name:       "silent error status",
+			args:       []string{"-s", "-l", srv.URL + "/missing"},
+			wantStdout: func(out string) bool { return out == "not here\n" },
+		},
+		{
+			name:       "silent with show-error",
+			args:       []string{"-s", "-S", "-l", srv.URL + "/missing"},
+			wantStdout: func(out string) bool { return out == "not here\n" },
+			wantStderr: "ERROR: Server returned status: 404 Not Found\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(dump)
+			stdout, stderr, code := runGurl(t, tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("gurl %q exited with %d, want %d (%s)", tt.args, code, tt.wantCode, stderr)
+			}
+			if !tt.wantStdout(stdout) {
+				t.Errorf("gurl %q stdout = %q", tt.args, stdout)
+			}
+			if stderr != tt.wantStderr {
+				t.Errorf("gurl %q stderr = %q, want %q", tt.args, stderr, tt.wantStderr)
+			}
+
+			dumped, err := os.ReadFile(dump)
+			if tt.wantDump != (err == nil) {
+				t.Fatalf("gurl %q header dump error = %v, want a dump: %v", tt.args, err, tt.wantDump)
+			}
+			if tt.wantDump && !(strings.HasPrefix(string(dumped), "HTTP/1.1 200 OK\r\n") && strings.HasSuffix(string(dumped), "\r\n\r\n")) {
+				t.Errorf("gurl %q header dump = %q", tt.args, dumped)
+			}
+		})
+	}
+}
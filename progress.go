@@ -0,0 +1,262 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket filled with '--limit-rate' bytes per second, up to one second worth of bytes.
+// It starts empty, so a transfer never goes faster than the limit
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// transferLimiter is shared by all the uploads & downloads, '--limit-rate' caps the whole run
+// and not each of the parallel transfers. It is only set while parsing the flags.
+var transferLimiter *rateLimiter
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: float64(rate), last: time.Now()}
+}
+
+// wait takes n tokens, sleeping for the missing ones. The tokens are taken under the lock, even when
+// missing, so the parallel transfers queue up behind each other while sleeping outside of it.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	missing := -l.tokens
+	l.mu.Unlock()
+
+	if missing <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(missing / l.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRate reads the bytes per second of '--limit-rate', with the 'K', 'M' & 'G' suffixes
+func parseRate(s string) (int64, error) {
+	spec := strings.TrimSpace(s)
+	if spec == "" {
+		return 0, nil
+	}
+	s = spec
+
+	unit := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		unit = 1 << 10
+	case "M":
+		unit = 1 << 20
+	case "G":
+		unit = 1 << 30
+	}
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid 'limit-rate' '%s', expected bytes per second like '500K' or '2M'", spec)
+	}
+	return n * unit, nil
+}
+
+// progressBar draws the bytes, the rate & the ETA of a transfer on stderr
+type progressBar struct {
+	total int64
+	done  int64
+	start time.Time
+	drawn time.Time
+}
+
+// progressRefresh is how often the bar is drawn again
+const progressRefresh = 200 * time.Millisecond
+
+func (p *progressBar) add(n int) {
+	p.done += int64(n)
+	if time.Since(p.drawn) >= progressRefresh {
+		p.draw()
+	}
+}
+
+func (p *progressBar) draw() {
+	p.drawn = time.Now()
+	elapsed := p.drawn.Sub(p.start).Seconds()
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed
+	}
+
+	line := fmt.Sprintf("%s  %s/s", formatBytes(p.done), formatBytes(int64(rate)))
+	if p.total > 0 {
+		percent := float64(p.done) / float64(p.total)
+		if percent > 1 {
+			percent = 1
+		}
+
+		const width = 30
+		filled := int(percent * width)
+		eta := "--:--"
+		if rate > 0 {
+			eta = formatETA(time.Duration(float64(p.total-p.done) / rate * float64(time.Second)))
+		}
+		line = fmt.Sprintf("[%s%s] %3.0f%%  %s / %s  %s/s  ETA %s",
+			strings.Repeat("#", filled), strings.Repeat("-", width-filled), percent*100,
+			formatBytes(p.done), formatBytes(p.total), formatBytes(int64(rate)), eta)
+	}
+
+	// The padding clears what is left of a longer previous line
+	fmt.Fprintf(os.Stderr, "\r%-80s", line)
+}
+
+func (p *progressBar) finish() {
+	p.draw()
+	fmt.Fprintln(os.Stderr)
+}
+
+func formatBytes(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value, i := float64(n)/1024, 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, units[i])
+}
+
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// meteredBody throttles a body with '--limit-rate' and shows its progress
+type meteredBody struct {
+	io.ReadCloser
+	ctx      context.Context
+	limiter  *rateLimiter
+	progress *progressBar
+	finished bool
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	// A read is never bigger than the bucket, so the rate stays smooth
+	if b.limiter != nil && len(p) > int(b.limiter.rate) {
+		p = p[:int(b.limiter.rate)]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if b.progress != nil {
+		b.progress.add(n)
+		if err != nil && !b.finished {
+			b.finished = true
+			b.progress.finish()
+		}
+	}
+
+	if b.limiter != nil && n > 0 {
+		if werr := b.limiter.wait(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (b *meteredBody) Close() error {
+	if b.progress != nil && !b.finished {
+		b.finished = true
+		b.progress.finish()
+	}
+	return b.ReadCloser.Close()
+}
+
+// meterBody applies '--limit-rate' & the progress bar to a body of total bytes, -1 when unknown
+func meterBody(ctx context.Context, body io.ReadCloser, total int64, progress bool) io.ReadCloser {
+	if limitRate == 0 && !progress {
+		return body
+	}
+
+	b := &meteredBody{ReadCloser: body, ctx: ctx, limiter: transferLimiter}
+	if progress {
+		b.progress = &progressBar{total: total, start: time.Now()}
+	}
+	return b
+}
+
+// meterUpload meters a request body every time it is sent
+func meterUpload(ctx context.Context, getBody func() (io.ReadCloser, error), total int64, progress bool) func() (io.ReadCloser, error) {
+	if limitRate == 0 && !progress {
+		return getBody
+	}
+
+	return func() (io.ReadCloser, error) {
+		body, err := getBody()
+		if err != nil {
+			return nil, err
+		}
+		return meterBody(ctx, body, total, progress), nil
+	}
+}
+
+// showProgress tells if a transfer gets a progress bar: always with '-#', and when stdout is a terminal.
+// A body written to that terminal does not get one, the bar would be drawn over it,
+// and the parallel transfers would draw over each other, they never get one.
+func showProgress(toStdout bool) bool {
+	if silentMode || parallelMode && len(transfers) > 1 {
+		return false
+	}
+	if progressMode {
+		return true
+	}
+	return !toStdout && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
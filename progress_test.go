@@ -0,0 +1,47 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{s: "", want: 0},
+		{s: "1024", want: 1024},
+		{s: "500K", want: 500 << 10},
+		{s: "2m", want: 2 << 20},
+		{s: " 1G ", want: 1 << 30},
+		{s: "0", wantErr: true},
+		{s: "-1K", wantErr: true},
+		{s: "K", wantErr: true},
+		{s: "1.5M", wantErr: true},
+		{s: "2MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRate(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRate(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseRate(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	netURL "net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// errProxyChallenge fails the CONNECT answered with a Negotiate challenge, so it is sent again with a token
+var errProxyChallenge = errors.New("proxy asked for Negotiate authentication")
+
+// newProxyFunc returns the proxy to use for each request.
+// '-x' wins over the 'HTTP_PROXY', 'HTTPS_PROXY' & 'ALL_PROXY' variables, 'NO_PROXY' & '--noproxy' apply to both.
+// Only the variables skip the proxy for localhost, an explicit '-x' is used for every host not in the no proxy list.
+// The SOCKS5 proxies always resolve the host names, the cluster names are often only known behind the bastion.
+func newProxyFunc() func(*http.Request) (*netURL.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	if all := getEnvAny("ALL_PROXY", "all_proxy"); all != "" {
+		if cfg.HTTPProxy == "" {
+			cfg.HTTPProxy = all
+		}
+		if cfg.HTTPSProxy == "" {
+			cfg.HTTPSProxy = all
+		}
+	}
+	if noProxy != "" {
+		cfg.NoProxy = noProxy
+	}
+
+	proxyFor := cfg.ProxyFunc()
+	if proxyAddr != "" {
+		explicit, err := netURL.Parse(proxyAddr)
+		proxyFor = func(target *netURL.URL) (*netURL.URL, error) {
+			if err != nil || inNoProxy(target.Hostname(), cfg.NoProxy) {
+				return nil, err
+			}
+			return explicit, nil
+		}
+	}
+
+	return func(req *http.Request) (*netURL.URL, error) {
+		proxy, err := proxyFor(req.URL)
+		if err != nil || proxy == nil {
+			return proxy, err
+		}
+
+		// The URL of the config is shared by all the requests
+		proxy = &netURL.URL{Scheme: proxy.Scheme, User: proxy.User, Host: proxy.Host}
+		if proxyUser != "" {
+			user, password, _ := strings.Cut(proxyUser, ":")
+			proxy.User = netURL.UserPassword(user, password)
+		}
+		return proxy, nil
+	}
+}
+
+// inNoProxy tells if the host is in the comma separated no proxy list. An entry is '*', an IP,
+// a CIDR or a domain that also covers its subdomains, a leading '.' or '*.' is ignored.
+func inNoProxy(host, list string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.Trim(entry, "[]")
+
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case net.ParseIP(entry) != nil:
+			if ip != nil && ip.Equal(net.ParseIP(entry)) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getEnvAny(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// validateProxy checks '-x' early, a missing scheme means an HTTP proxy
+func validateProxy(addr string) (string, error) {
+	if addr == "" {
+		return "", nil
+	}
+
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+
+	u, err := netURL.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return addr, nil
+	default:
+		return "", fmt.Errorf("unsupported proxy scheme '%s', use http, https, socks5 or socks5h", u.Scheme)
+	}
+}
+
+// proxyNegotiator answers the Negotiate challenges of the proxy, the token is built for
+// 'HTTP/<proxy-host>' and never mixed with the one sent to the origin
+type proxyNegotiator struct {
+	mu         sync.Mutex
+	spnego     Provider
+	proxyFunc  func(*http.Request) (*netURL.URL, error)
+	challenged map[string]bool
+	// ticketed is closed once the first token for the proxy is built, the service ticket is then cached
+	ticketed map[string]chan struct{}
+}
+
+func newProxyNegotiator(proxyFunc func(*http.Request) (*netURL.URL, error)) *proxyNegotiator {
+	return &proxyNegotiator{spnego: New(), proxyFunc: proxyFunc, challenged: map[string]bool{}, ticketed: map[string]chan struct{}{}}
+}
+
+func (p *proxyNegotiator) isChallenged(proxyURL *netURL.URL) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.challenged[proxyURL.Host]
+}
+
+// challenge records a 407 asking for Negotiate, it reports if it was a new one
+func (p *proxyNegotiator) challenge(proxyURL *netURL.URL, resp *http.Response) bool {
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		return false
+	}
+
+	offered := false
+	for _, v := range resp.Header.Values("Proxy-Authenticate") {
+		if strings.HasPrefix(strings.TrimSpace(v), "Negotiate") {
+			offered = true
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !offered || p.challenged[proxyURL.Host] {
+		return false
+	}
+
+	p.challenged[proxyURL.Host] = true
+	return true
+}
+
+// token builds a Negotiate token for the proxy. The lock is not held over the KDC exchange:
+// the first caller gets the service ticket, the parallel ones wait for it and then build their own
+// token from the cache, a token is not shared as the proxy would reject it as a replay.
+func (p *proxyNegotiator) token(ctx context.Context, proxyURL *netURL.URL) (string, error) {
+	// The SPNEGO provider works on requests, this one only carries the proxy host
+	probe, err := http.NewRequestWithContext(ctx, http.MethodConnect, "http://"+proxyURL.Host, nil)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	ticketed, waiting := p.ticketed[proxyURL.Host]
+	if !waiting {
+		ticketed = make(chan struct{})
+		p.ticketed[proxyURL.Host] = ticketed
+	}
+	p.mu.Unlock()
+
+	if waiting {
+		select {
+		case <-ticketed:
+		case <-ctx.Done():
+			return "", &Error{Err: fmt.Errorf("the Kerberos exchange did not finish in time. Because: %w", ctx.Err())}
+		}
+	}
+
+	err = setSPNEGOHeaderInTime(p.spnego, probe)
+	if !waiting {
+		p.mu.Lock()
+		if err != nil {
+			// The next caller tries the KDC again
+			delete(p.ticketed, proxyURL.Host)
+		}
+		p.mu.Unlock()
+		close(ticketed)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("cannot build the Negotiate token for the proxy '%s'. Because: %w", proxyURL.Host, err)
+	}
+	return probe.Header.Get("Authorization"), nil
+}
+
+// connectHeader is the 'GetProxyConnectHeader' of the transport, for the HTTPS tunnels
+func (p *proxyNegotiator) connectHeader(ctx context.Context, proxyURL *netURL.URL, target string) (http.Header, error) {
+	if !p.isChallenged(proxyURL) {
+		return nil, nil
+	}
+
+	token, err := p.token(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	return http.Header{"Proxy-Authorization": {token}}, nil
+}
+
+// connectResponse is the 'OnProxyConnectResponse' of the transport
+func (p *proxyNegotiator) connectResponse(ctx context.Context, proxyURL *netURL.URL, connectReq *http.Request, connectRes *http.Response) error {
+	if p.challenge(proxyURL, connectRes) {
+		return errProxyChallenge
+	}
+	return nil
+}
+
+// proxyAuthTransport sends the request again once the proxy asked for Negotiate.
+// Plain HTTP requests carry the token themselves, HTTPS ones get it on the CONNECT.
+type proxyAuthTransport struct {
+	next       http.RoundTripper
+	negotiator *proxyNegotiator
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (t *proxyAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxyURL, err := t.negotiator.proxyFunc(req)
+	if err != nil || proxyURL == nil || strings.HasPrefix(proxyURL.Scheme, "socks5") {
+		return t.next.RoundTrip(req)
+	}
+
+	resp, err := t.send(req, proxyURL, false)
+	switch {
+	case err != nil && errors.Is(err, errProxyChallenge):
+	case err == nil && req.URL.Scheme == "http" && t.negotiator.challenge(proxyURL, resp):
+		discard(resp)
+	default:
+		return resp, err
+	}
+
+	verbosef("* Proxy '%s' asked for Negotiate, sending a token\n", proxyURL.Host)
+	return t.send(req, proxyURL, true)
+}
+
+func (t *proxyAuthTransport) send(req *http.Request, proxyURL *netURL.URL, replay bool) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if replay && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	if req.URL.Scheme == "http" && t.negotiator.isChallenged(proxyURL) {
+		token, err := t.negotiator.token(req.Context(), proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Proxy-Authorization", token)
+	}
+
+	return t.next.RoundTrip(r)
+}
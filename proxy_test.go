@@ -0,0 +1,161 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	netURL "net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInNoProxy(t *testing.T) {
+	tests := []struct {
+		host string
+		list string
+		want bool
+	}{
+		{host: "nn1.example.com", list: "", want: false},
+		{host: "nn1.example.com", list: "*", want: true},
+		{host: "nn1.example.com", list: "example.com", want: true},
+		{host: "nn1.example.com", list: ".example.com", want: true},
+		{host: "nn1.example.com", list: "*.example.com", want: true},
+		{host: "example.com", list: ".example.com", want: true},
+		{host: "badexample.com", list: "example.com", want: false},
+		{host: "NN1.Example.com.", list: "nn1.example.com:9870", want: true},
+		{host: "10.1.2.3", list: "localhost, 10.0.0.0/8", want: true},
+		{host: "192.168.1.1", list: "10.0.0.0/8", want: false},
+		{host: "127.0.0.1", list: "127.0.0.1", want: true},
+		{host: "::1", list: "[::1]:8080", want: true},
+		{host: "localhost", list: "127.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := inNoProxy(tt.host, tt.list); got != tt.want {
+			t.Errorf("inNoProxy(%q, %q) = %v, want %v", tt.host, tt.list, got, tt.want)
+		}
+	}
+}
+
+// slowKDC is a Provider whose KDC answers after 'delay', the tokens are numbered
+type slowKDC struct {
+	delay     time.Duration
+	mu        sync.Mutex
+	calls     int
+	firstDone bool
+	early     bool
+}
+
+func (k *slowKDC) SetSPNEGOHeader(req *http.Request) error {
+	k.mu.Lock()
+	k.calls++
+	n := k.calls
+	if n > 1 && !k.firstDone {
+		k.early = true
+	}
+	delay := k.delay
+	if n > 1 {
+		// The service ticket is cached after the first exchange
+		delay = 0
+	}
+	k.mu.Unlock()
+
+	time.Sleep(delay)
+	req.Header.Set("Authorization", "Negotiate token-"+strconv.Itoa(n))
+
+	k.mu.Lock()
+	if n == 1 {
+		k.firstDone = true
+	}
+	k.mu.Unlock()
+	return nil
+}
+
+func TestProxyNegotiatorToken(t *testing.T) {
+	defer func(saved time.Duration) { kdcTimeout = saved }(kdcTimeout)
+	proxyURL := &netURL.URL{Scheme: "http", Host: "proxy.example.com:3128"}
+
+	t.Run("kdc timeout", func(t *testing.T) {
+		kdcTimeout = 50 * time.Millisecond
+		p := newProxyNegotiator(nil)
+		p.spnego = &slowKDC{delay: 5 * time.Second}
+
+		locked := make(chan struct{})
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			// The lock is free while the KDC is asked
+			p.isChallenged(proxyURL)
+			close(locked)
+		}()
+
+		start := time.Now()
+		_, err := p.token(context.Background(), proxyURL)
+		if err == nil {
+			t.Fatal("token() did not fail on a KDC slower than kdc-timeout")
+		}
+		if got := exitCode(err); got != exitKerberos {
+			t.Errorf("exit code = %d, want %d (%v)", got, exitKerberos, err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("token() took %v, want about kdc-timeout", elapsed)
+		}
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			t.Error("the lock was held over the KDC exchange")
+		}
+	})
+
+	t.Run("parallel tokens", func(t *testing.T) {
+		kdcTimeout = 5 * time.Second
+		kdc := &slowKDC{delay: 100 * time.Millisecond}
+		p := newProxyNegotiator(nil)
+		p.spnego = kdc
+
+		const n = 5
+		tokens := make(chan string, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := p.token(context.Background(), proxyURL)
+				if err != nil {
+					t.Errorf("token() = %v", err)
+				}
+				tokens <- token
+			}()
+		}
+		wg.Wait()
+		close(tokens)
+
+		seen := map[string]bool{}
+		for token := range tokens {
+			if seen[token] {
+				t.Errorf("token %q was given twice", token)
+			}
+			seen[token] = true
+		}
+		if kdc.calls != n {
+			t.Errorf("%d tokens were built, want %d", kdc.calls, n)
+		}
+		if kdc.early {
+			t.Error("a token was asked before the first one got the service ticket")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	netURL "net/url"
+	"os"
+)
+
+// credentialHeaders are never forwarded to another origin unless '--location-trusted' is set
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// checkRedirect decides if a redirect is followed.
+//
+// WebHDFS OPEN/CREATE, Knox and the standby YARN RMs answer with redirects to
+// other hosts. The SPNEGO header is recomputed for every hop by spnegoTransport,
+// any other credential is only kept while the origin stays the same.
+// 307 & 308 resend the body, net/http does that through 'Request.GetBody'.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		if maxRedirects == 0 {
+			return http.ErrUseLastResponse
+		}
+		return withExitCode(exitTooManyRedirects, fmt.Errorf("stopped after %d redirects", maxRedirects))
+	}
+
+	if stats := statsFromContext(req.Context()); stats != nil {
+		stats.addRedirect()
+	}
+
+	prev := via[len(via)-1]
+	if showRedirects {
+		fmt.Fprintf(os.Stderr, "REDIRECT: %d %s -> %s\n", req.Response.StatusCode, prev.URL, req.URL)
+	}
+
+	verbosef("* Following the %d redirect to '%s'\n", req.Response.StatusCode, redactURL(req.URL))
+
+	first := via[0]
+	for _, name := range credentialHeaders {
+		switch {
+		case locationTrusted && first.Header.Get(name) != "":
+			req.Header[name] = first.Header.Values(name)
+		case !locationTrusted && !isSameOrigin(first.URL, req.URL) && req.Header.Get(name) != "":
+			verbosef("* Not sending the '%s' header to another origin\n", name)
+			req.Header.Del(name)
+		}
+	}
+
+	return nil
+}
+
+func isSameOrigin(a, b *netURL.URL) bool {
+	return a.Scheme == b.Scheme && a.Hostname() == b.Hostname() && portOf(a) == portOf(b)
+}
+
+func portOf(u *netURL.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
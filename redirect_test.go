@@ -0,0 +1,124 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// hostToken is a SPNEGO provider whose token names the host it was made for
+type hostToken struct{}
+
+func (hostToken) SetSPNEGOHeader(req *http.Request) error {
+	req.Header.Set("Authorization", "Negotiate token-for-"+req.URL.Host)
+	return nil
+}
+
+func TestCheckRedirect(t *testing.T) {
+	defer func(max int, trusted bool, r *hostResolver) {
+		maxRedirects, locationTrusted, resolver = max, trusted, r
+	}(maxRedirects, locationTrusted, resolver)
+
+	r, err := newHostResolver(nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("newHostResolver error = %v", err)
+	}
+	resolver = r
+
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "auth="+r.Header.Get("Authorization")+";cookie="+r.Header.Get("Cookie"))
+	}
+
+	// The DataNode is another origin, same address on another port
+	dataNode := httptest.NewServer(http.HandlerFunc(echo))
+	defer dataNode.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", echo)
+	mux.HandleFunc("/cross", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, dataNode.URL+"/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/same", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	nameNode := httptest.NewServer(mux)
+	defer nameNode.Close()
+
+	const credentials = "auth=Basic aGRmczo=;cookie=hadoop.auth=abc"
+	dataNodeHost := dataNode.Listener.Addr().String()
+
+	tests := []struct {
+		name       string
+		path       string
+		maxRedirs  int
+		trusted    bool
+		spnego     bool
+		wantStatus int
+		wantBody   string
+		wantExit   int
+	}{
+		{name: "cross origin drops the credentials", path: "/cross", maxRedirs: 10, wantStatus: http.StatusOK, wantBody: "auth=;cookie="},
+		{name: "same origin keeps the credentials", path: "/same", maxRedirs: 10, wantStatus: http.StatusOK, wantBody: credentials},
+		{name: "location-trusted", path: "/cross", maxRedirs: 10, trusted: true, wantStatus: http.StatusOK, wantBody: credentials},
+		{
+			name: "SPNEGO token made for the new host", path: "/cross", maxRedirs: 10, spnego: true,
+			wantStatus: http.StatusOK, wantBody: "auth=Negotiate token-for-" + dataNodeHost + ";cookie=",
+		},
+		{name: "max-redirs", path: "/loop", maxRedirs: 3, wantExit: exitTooManyRedirects},
+		{name: "max-redirs 0", path: "/cross", maxRedirs: 0, wantStatus: http.StatusTemporaryRedirect},
+	}
+
+	for _, tt := range tests {
+		maxRedirects, locationTrusted = tt.maxRedirs, tt.trusted
+
+		client := newClient()
+		if tt.spnego {
+			client.Transport = &spnegoTransport{Transport: client.Transport, spnego: hostToken{}}
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, nameNode.URL+tt.path, nil)
+		if !tt.spnego {
+			req.SetBasicAuth("hdfs", "")
+		}
+		req.Header.Set("Cookie", "hadoop.auth=abc")
+
+		resp, err := client.Do(req)
+		if tt.wantExit != 0 {
+			if got := exitCode(err); got != tt.wantExit {
+				t.Errorf("%s: exit code = %d, want %d (error = %v)", tt.name, got, tt.wantExit, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+		if tt.wantBody != "" && string(body) != tt.wantBody {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.wantBody)
+		}
+	}
+}
@@ -15,13 +15,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
+	"strings"
 )
 
+// httpMethod is a validated request method, any RFC 7230 token is accepted
 type httpMethod string
 
 const (
@@ -32,72 +35,174 @@ const (
 	httpPUT     httpMethod = "PUT"
 	httpOPTIONS httpMethod = "OPTIONS"
 	httpHEAD    httpMethod = "HEAD"
+	httpTRACE   httpMethod = "TRACE"
+
+	// WebDAV (RFC 4918)
+	httpPROPFIND  httpMethod = "PROPFIND"
+	httpPROPPATCH httpMethod = "PROPPATCH"
+	httpMKCOL     httpMethod = "MKCOL"
+	httpCOPY      httpMethod = "COPY"
+	httpMOVE      httpMethod = "MOVE"
+	httpLOCK      httpMethod = "LOCK"
+	httpUNLOCK    httpMethod = "UNLOCK"
 )
 
+// knownMethods are matched case-insensitively, everything else is sent as typed
+var knownMethods = []httpMethod{
+	httpGET, httpPOST, httpPATCH, httpDELETE, httpPUT, httpOPTIONS, httpHEAD, httpTRACE,
+	httpPROPFIND, httpPROPPATCH, httpMKCOL, httpCOPY, httpMOVE, httpLOCK, httpUNLOCK,
+}
+
 func (m httpMethod) ToString() (string, error) {
 	return methodToString(m)
 }
 
 func stringToMethod(reqType string) (httpMethod, error) {
 	// Validate the HTTP Method
-	switch reqType {
-	case "GET":
-		return httpGET, nil
-	case "POST":
-		return httpPOST, nil
-	case "PATCH":
-		return httpPATCH, nil
-	case "DELETE":
-		return httpDELETE, nil
-	case "PUT":
-		return httpPUT, nil
-	case "OPTIONS":
-		return httpOPTIONS, nil
-	case "HEAD":
-		return httpHEAD, nil
-	default:
-		return "", errors.New("invalid http method")
+	if !isToken(reqType) {
+		return "", fmt.Errorf("invalid http method '%s'", reqType)
+	}
+
+	if m := httpMethod(strings.ToUpper(reqType)); isInSlice(m, knownMethods) {
+		return m, nil
 	}
+
+	return httpMethod(reqType), nil
 }
 
 func methodToString(reqType httpMethod) (string, error) {
-	switch reqType {
-	case "DELETE", "GET", "POST", "PATCH", "PUT", "OPTIONS", "HEAD":
-		return string(reqType), nil
+	if !isToken(string(reqType)) {
+		return string(reqType), fmt.Errorf("invalid http method '%s'", reqType)
+	}
+
+	return string(reqType), nil
+}
+
+// httpProtocols picks the HTTP versions of the transport.
+// Go only does HTTP/2 with a custom TLS config when asked, so HTTP/1.1 stays the default.
+// The fallback of '--http3' offers HTTP/2 like curl does.
+func httpProtocols() *http.Protocols {
+	protocols := &http.Protocols{}
+	switch {
+	case forceHTTP2, http3Mode:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	case http2PriorKnowledge:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+	}
+	return protocols
+}
+
+// alpnProtocols is what the TLS handshake offers, it is set here as the traced connections do their own handshake
+func alpnProtocols() []string {
+	switch {
+	case forceHTTP2, http3Mode:
+		return []string{"h2", "http/1.1"}
+	case http2PriorKnowledge:
+		return []string{"h2"}
 	default:
-		return string(reqType), errors.New("invalid http method")
+		return []string{"http/1.1"}
 	}
 }
 
-func makeRequest(requestType httpMethod, url string) ([]byte, int, error) {
-	//
+// newClient builds the HTTP client, the transport is wrapped with SPNEGO when Kerberos is enabled
+func newClient() *http.Client {
+	// The socket is local, the proxy variables do not apply to it
+	proxyFunc := newProxyFunc()
+	if unixSocket != "" {
+		proxyFunc = nil
+	}
+
 	clientTransport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: !enforceTLSVerify},
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: !enforceTLSVerify, RootCAs: caCertPool},
+		DialContext:         newDialFunc(),
+		TLSHandshakeTimeout: connectTimeout,
+		Proxy:               proxyFunc,
+		Protocols:           httpProtocols(),
+		// The responses are only decoded with '--compressed', the encoded bytes are kept otherwise
+		DisableCompression: true,
+	}
+	clientTransport.TLSClientConfig.NextProtos = alpnProtocols()
+
+	if readTimeout > 0 {
+		clientTransport.DialContext = withIdleTimeout(clientTransport.DialContext, readTimeout)
+	}
+
+	if tracer != nil {
+		clientTransport.DialTLSContext = tracer.wrapDialTLS(clientTransport.DialContext, clientTransport.TLSClientConfig)
+		clientTransport.DialContext = tracer.wrapDial(clientTransport.DialContext)
 	}
 
 	// Default HTTP Client
 	client := &http.Client{
-		Transport: clientTransport,
+		Transport:     clientTransport,
+		CheckRedirect: checkRedirect,
+	}
+
+	if http3Only {
+		client.Transport = newHTTP3Transport(clientTransport.TLSClientConfig)
+	} else if http3Mode {
+		client.Transport = &http3Fallback{h3: newHTTP3Transport(clientTransport.TLSClientConfig), tcp: clientTransport}
+	}
+
+	// The verbose output shows the requests with the SPNEGO header already set
+	if verboseMode {
+		client.Transport = &verboseTransport{next: client.Transport}
+	}
+
+	if compressedMode {
+		client.Transport = &decompressTransport{next: client.Transport}
+	}
+
+	if proxyNegotiate && !http3Mode && !http3Only {
+		negotiator := newProxyNegotiator(proxyFunc)
+		clientTransport.GetProxyConnectHeader = negotiator.connectHeader
+		clientTransport.OnProxyConnectResponse = negotiator.connectResponse
+		client.Transport = &proxyAuthTransport{next: client.Transport, negotiator: negotiator}
 	}
 
 	// If required
 	// Create the HTTP Client for Kerberos
 	if isKerberized {
-		client = &http.Client{Transport: &spnegoTransport{
-			// This technically copies a mutex, but since we've just created
-			// the object, we know that this mutex is unlocked.
-			Transport: *clientTransport,
-		}}
+		client.Transport = &spnegoTransport{
+			Transport: client.Transport,
+			spnego:    New(),
+		}
 	}
 
+	return client
+}
+
+// newRequest builds a request carrying the basic auth, the User-Agent and the user supplied headers.
+// The body is opened through getBody, so the transports can send it again
+// when the request needs to be repeated (auth retries, redirects)
+func newRequest(ctx context.Context, requestType httpMethod, url string, getBody func() (io.ReadCloser, error), contentType string) (*http.Request, error) {
 	reqType, err := methodToString(requestType)
 	if err != nil {
-		return []byte{}, 0, err
+		return nil, err
 	}
 
-	req, err := http.NewRequest(reqType, url, nil)
+	var body io.ReadCloser
+	if getBody != nil {
+		if body, err = getBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, reqType, url, body)
 	if err != nil {
-		return []byte{}, 400, fmt.Errorf("cannot build the '%s' request for the URL: '%s'. Because: %w", requestType, url, err)
+		return nil, fmt.Errorf("cannot build the '%s' request for the URL: '%s'. Because: %w", requestType, url, err)
+	}
+
+	if getBody != nil {
+		req.GetBody = getBody
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
 	if isBasicAuth != "" {
@@ -110,63 +215,127 @@ func makeRequest(requestType httpMethod, url string) ([]byte, int, error) {
 		req.Header.Add("User-Agent", clientUserAgent)
 	}
 
-	resp, err := client.Do(req)
+	headers, err := parseHeaders(requestHeaders)
 	if err != nil {
-		return []byte{}, 400, fmt.Errorf("unable to make the '%s' request for the URL: '%s'. Because: %w", reqType, url, err)
+		return nil, err
+	}
+	applyHeaders(req, headers)
+
+	return req, nil
+}
+
+// makeRequest runs a transfer with the shared client, the response goes to w unless it is saved to a file
+func makeRequest(ctx context.Context, client *http.Client, requestType httpMethod, t transfer, w io.Writer) (_ []byte, _ int, err error) {
+	// SPNEGO is computed for the endpoint picked by the failover
+	client = withFailover(client, t.endpoints)
+	url := t.url
+	stats := newTransferStats()
+
+	// '--json-result' takes the body in the record, or the path of the '-o' file
+	var result *transferResult
+	var body bytes.Buffer
+	if results != nil {
+		result = newTransferResult(requestType, url)
+		w = &body
+		defer func() {
+			result.setStats(stats)
+			result.setError(err)
+			results.write(result)
+		}()
+	}
+
+	var getBody func() (io.ReadCloser, error)
+	contentType := ""
+	if len(formFields) > 0 {
+		form, err := newFormBody(formFields)
+		if err != nil {
+			return []byte{}, 0, err
+		}
+		getBody, contentType = form.Reader, form.ContentType()
+	}
+
+	if gzipRequestBody && getBody != nil {
+		getBody = gzipBody(getBody)
+	}
+	if getBody != nil {
+		getBody = stats.countUpload(meterUpload(ctx, getBody, -1, showProgress(false)))
+	}
+
+	var out *download
+	if t.output != "" && !headOnly {
+		var err error
+		if out, err = newDownload(t.output); err != nil {
+			return []byte{}, 0, withExitCode(exitWriteError, err)
+		}
+	}
+
+	resp, err := doWithRetry(ctx, client, func() (*http.Request, error) {
+		req, err := newRequest(ctx, requestType, url, getBody, contentType)
+		if err != nil {
+			return nil, err
+		}
+
+		if out != nil {
+			out.setRange(req)
+		}
+		if gzipRequestBody && getBody != nil {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+
+		req = req.WithContext(stats.attach(req.Context()))
+		if verboseMode {
+			req = req.WithContext(verboseTrace(req.Context()))
+		}
+		return req, nil
+	})
+	if err != nil {
+		return []byte{}, 0, fmt.Errorf("unable to make the '%s' request for the URL: '%s'. Because: %w", requestType, url, err)
 	}
 
 	defer resp.Body.Close()
+	if result != nil {
+		result.setResponse(resp)
+	}
+	resp.Body = stats.countBody(&receivedBody{ReadCloser: resp.Body})
+	if !headOnly {
+		resp.Body = meterBody(ctx, resp.Body, resp.ContentLength, showProgress(out == nil))
+	}
 
-	switch rcode := resp.StatusCode; {
-	case rcode <= 299:
-		if outputFile != "" {
-
-			if _, err := os.Stat(outputFile); err == nil {
-				if err := os.Remove(outputFile); err != nil {
-					return []byte{}, 400, fmt.Errorf("unable to tuncate the existing file: '%s'. Because: %w", outputFile, err)
-				}
-			} else if !os.IsNotExist(err) {
-				return []byte{}, 400, fmt.Errorf("unable to access the existing file: '%s'. Because: %w", outputFile, err)
-			}
-
-			f, e := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY, 0o644)
-			if e != nil {
-				return []byte{}, 400, fmt.Errorf("unable to create the output file at: '%s'. Because: %w", outputFile, err)
-			}
-			defer f.Close()
-
-			_, err = f.ReadFrom(resp.Body)
-			if err != nil {
-				//
-				return []byte{}, resp.StatusCode, err
-			}
-			fmt.Println("INFO: Output redirected the file: '" + outputFile + "'")
-		} else {
-			body := []byte{}
-			_, readErr := resp.Body.Read(body)
-			if readErr != nil {
-				fmt.Println("ERROR: Unabled to read the response body")
-				return []byte{}, resp.StatusCode, readErr
-			}
-			fmt.Println("HEADERS: ")
-			for k, v := range resp.Header {
-				fmt.Printf("'%s' : '%s'\n", k, v)
-			}
-			fmt.Println("BODY: \n", string(body))
+	// '--fail' drops the body of the HTTP errors, '--fail-with-body' keeps it
+	failed := (failOnError || failWithBody) && resp.StatusCode >= 400
+	if !failed || failWithBody {
+		if err := writeResponse(w, resp, out); err != nil {
+			return []byte{}, resp.StatusCode, err
+		}
+
+		if result != nil && out != nil && body.Len() == 0 {
+			result.BodyFile = out.path
+		} else if result != nil && !headOnly {
+			result.setBody(&body)
 		}
-	case rcode >= 300:
-		fmt.Println("ERROR: Server returned status: ", resp.Status)
-		body := []byte{}
-		_, readErr := resp.Body.Read(body)
-		if readErr != nil {
-			fmt.Println("ERROR: Unable to read the response body")
-			return []byte{}, resp.StatusCode, readErr
+	}
+	stats.done()
+
+	if writeOutFormat != "" {
+		if err := writeOut(w, writeOutFormat, resp, stats); err != nil {
+			return []byte{}, resp.StatusCode, err
 		}
-		fmt.Println("HEADERS: ")
-		for k, v := range resp.Header {
-			fmt.Printf("'%s' : '%s'\n", k, v)
+	}
+
+	if failed {
+		if len(transfers) > 1 {
+			return []byte{}, resp.StatusCode, withExitCode(exitHTTPError, fmt.Errorf("'%s' returned status: %s", url, resp.Status))
+		}
+		return []byte{}, resp.StatusCode, withExitCode(exitHTTPError, fmt.Errorf("the server returned status: %s", resp.Status))
+	}
+
+	// A resumed download that was already complete is answered with a 416
+	if resp.StatusCode >= 300 && !(resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && out != nil && out.offset > 0) {
+		if len(transfers) > 1 {
+			logErrorf("ERROR: '%s' returned status: %s\n", url, resp.Status)
+		} else {
+			logErrorf("ERROR: Server returned status: %s\n", resp.Status)
 		}
-		fmt.Println("BODY: \n", string(body))
 	}
 
 	return []byte{}, resp.StatusCode, nil
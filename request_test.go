@@ -0,0 +1,129 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStringToMethod(t *testing.T) {
+	tests := []struct {
+		reqType string
+		want    httpMethod
+		wantErr bool
+	}{
+		{reqType: "GET", want: httpGET},
+		{reqType: "post", want: httpPOST},
+		{reqType: "Propfind", want: httpPROPFIND},
+		{reqType: "unlock", want: httpUNLOCK},
+		// Unknown methods are sent as they were typed
+		{reqType: "Purge", want: httpMethod("Purge")},
+		{reqType: "", wantErr: true},
+		{reqType: "GET /", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := stringToMethod(tt.reqType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("stringToMethod(%q) error = %v, wantErr %v", tt.reqType, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("stringToMethod(%q) = %q, want %q", tt.reqType, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPVersions(t *testing.T) {
+	defer func(http11, http2, prior, kerberized bool, r *hostResolver) {
+		forceHTTP11, forceHTTP2, http2PriorKnowledge, isKerberized, resolver = http11, http2, prior, kerberized, r
+	}(forceHTTP11, forceHTTP2, http2PriorKnowledge, isKerberized, resolver)
+
+	r, err := newHostResolver(nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("newHostResolver error = %v", err)
+	}
+	resolver = r
+
+	// The Kerberos path answers with a Negotiate challenge until it gets a token
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.URL.Path == "/kerberos" && auth == "" {
+			w.Header().Set("WWW-Authenticate", "Negotiate")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, r.Proto+" "+auth)
+	})
+
+	tlsH2 := httptest.NewUnstartedServer(handler)
+	tlsH2.EnableHTTP2 = true
+	tlsH2.StartTLS()
+	defer tlsH2.Close()
+
+	tlsH1 := httptest.NewTLSServer(handler)
+	defer tlsH1.Close()
+
+	h2c := httptest.NewUnstartedServer(handler)
+	h2c.Config.Protocols = &http.Protocols{}
+	h2c.Config.Protocols.SetHTTP1(true)
+	h2c.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2c.Start()
+	defer h2c.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		http11     bool
+		http2      bool
+		prior      bool
+		kerberized bool
+		want       string
+	}{
+		{name: "default over TLS", url: tlsH2.URL, want: "HTTP/1.1 "},
+		{name: "http1.1 over TLS", url: tlsH2.URL, http11: true, want: "HTTP/1.1 "},
+		{name: "http2 over TLS", url: tlsH2.URL, http2: true, want: "HTTP/2.0 "},
+		{name: "http2 without h2 on the server", url: tlsH1.URL, http2: true, want: "HTTP/1.1 "},
+		{name: "http2 over cleartext", url: h2c.URL, http2: true, want: "HTTP/1.1 "},
+		{name: "prior knowledge over cleartext", url: h2c.URL, prior: true, want: "HTTP/2.0 "},
+		{name: "prior knowledge over TLS", url: tlsH2.URL, prior: true, want: "HTTP/2.0 "},
+		{name: "spnego over http2", url: tlsH2.URL + "/kerberos", http2: true, kerberized: true, want: "HTTP/2.0 Negotiate token-for-" + strings.TrimPrefix(tlsH2.URL, "https://")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forceHTTP11, forceHTTP2, http2PriorKnowledge, isKerberized = tt.http11, tt.http2, tt.prior, tt.kerberized
+			client := newClient()
+			if tt.kerberized {
+				client.Transport.(*spnegoTransport).spnego = hostToken{}
+			}
+
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatalf("GET %s error = %v", tt.url, err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("GET %s = %d %q, want %q", tt.url, resp.StatusCode, body, tt.want)
+			}
+		})
+	}
+}
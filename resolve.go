@@ -0,0 +1,266 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// hostResolver applies the '--connect-to', '--resolve', '--hosts-file' & '--dns-servers' overrides.
+// The dial of every transport and the SPN lookup go through it, so the ticket is for the host really reached
+type hostResolver struct {
+	connectTo []connectTo
+	pinned    map[string][]string // 'host:port' or 'host:*' => addresses
+	hosts     map[string][]string // name => addresses of the hosts file
+	names     map[string][]string // address => names of the hosts file
+	dns       *net.Resolver
+}
+
+// connectTo sends the connections for host:port to toHost:toPort, the empty fields match or keep anything
+type connectTo struct {
+	host, port, toHost, toPort string
+}
+
+func newHostResolver(resolves []string, connects []string, hostsFile string, dnsServers string) (*hostResolver, error) {
+	r := &hostResolver{
+		pinned: map[string][]string{},
+		hosts:  map[string][]string{},
+		names:  map[string][]string{},
+		dns:    net.DefaultResolver,
+	}
+
+	for _, entry := range resolves {
+		fields, err := splitHostFields(entry, 3)
+		if err != nil || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+			return nil, fmt.Errorf("invalid 'resolve' entry '%s', expected 'host:port:address[,address]'", entry)
+		}
+
+		addrs := []string{}
+		for _, a := range strings.Split(fields[2], ",") {
+			a = strings.Trim(strings.TrimSpace(a), "[]")
+			if net.ParseIP(a) == nil {
+				return nil, fmt.Errorf("invalid address '%s' in the 'resolve' entry '%s'", a, entry)
+			}
+			addrs = append(addrs, a)
+		}
+		r.pinned[net.JoinHostPort(strings.ToLower(fields[0]), fields[1])] = addrs
+	}
+
+	for _, entry := range connects {
+		fields, err := splitHostFields(entry, 4)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'connect-to' entry '%s', expected 'host:port:connect-to-host:connect-to-port'", entry)
+		}
+		r.connectTo = append(r.connectTo, connectTo{
+			host: strings.ToLower(fields[0]), port: fields[1],
+			toHost: fields[2], toPort: fields[3],
+		})
+	}
+
+	if hostsFile != "" {
+		if err := r.readHostsFile(hostsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if dnsServers != "" {
+		dns, err := newDNSResolver(dnsServers)
+		if err != nil {
+			return nil, err
+		}
+		r.dns = dns
+	}
+
+	return r, nil
+}
+
+// splitHostFields splits 'host:port:...' in n fields, the IPv6 addresses are given in brackets
+func splitHostFields(s string, n int) ([]string, error) {
+	fields := []string{}
+	for len(fields) < n-1 {
+		end := strings.IndexByte(s, ':')
+		if strings.HasPrefix(s, "[") {
+			if closing := strings.IndexByte(s, ']'); closing > 0 && len(s) > closing+1 && s[closing+1] == ':' {
+				end = closing + 1
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("expected %d fields in '%s'", n, s)
+		}
+		fields = append(fields, strings.Trim(s[:end], "[]"))
+		s = s[end+1:]
+	}
+	return append(fields, s), nil
+}
+
+// readHostsFile reads a file in the '/etc/hosts' format, it takes precedence over the DNS
+func (r *hostResolver) readHostsFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to read the hosts file at: '%s'. Because: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		addr := fields[0]
+		if net.ParseIP(addr) == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(name)
+			r.hosts[name] = append(r.hosts[name], addr)
+			r.names[addr] = append(r.names[addr], name)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read the hosts file at: '%s'. Because: %w", path, err)
+	}
+	return nil
+}
+
+// newDNSResolver asks the comma separated 'host[:port]' servers in order instead of the system ones
+func newDNSResolver(servers string) (*net.Resolver, error) {
+	addrs := []string{}
+	for _, s := range strings.Split(servers, ",") {
+		s = strings.TrimSpace(s)
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(strings.Trim(s, "[]"), "53")
+		}
+		if host, _, _ := net.SplitHostPort(s); net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid DNS server '%s', expected an IP address", s)
+		}
+		addrs = append(addrs, s)
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			var err error
+			for _, addr := range addrs {
+				var conn net.Conn
+				if conn, err = d.DialContext(ctx, network, addr); err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		},
+	}, nil
+}
+
+// target is where the connections for host:port really go with '--connect-to'
+func (r *hostResolver) target(host string, port string) (string, string) {
+	for _, c := range r.connectTo {
+		if (c.host == "" || c.host == strings.ToLower(host)) && (c.port == "" || c.port == port) {
+			if c.toHost != "" {
+				host = c.toHost
+			}
+			if c.toPort != "" {
+				port = c.toPort
+			}
+			return host, port
+		}
+	}
+	return host, port
+}
+
+// override gives the addresses of '--resolve' or of the hosts file, the DNS is not asked for them
+func (r *hostResolver) override(host string, port string) ([]string, string, bool) {
+	host = strings.ToLower(host)
+	if addrs, ok := r.pinned[net.JoinHostPort(host, port)]; ok {
+		return addrs, "resolve", true
+	}
+	if addrs, ok := r.pinned[net.JoinHostPort(host, "*")]; ok {
+		return addrs, "resolve", true
+	}
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, "hosts-file", true
+	}
+	return nil, "", false
+}
+
+func (r *hostResolver) lookup(ctx context.Context, host string, port string) ([]string, error) {
+	if addrs, _, ok := r.override(host, port); ok {
+		return addrs, nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	return r.dns.LookupHost(ctx, host)
+}
+
+// route applies the overrides to the address to connect to, the addresses are only given by '--resolve' & the hosts file
+func (r *hostResolver) route(addr string) (string, string, []string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if h, p := r.target(host, port); h != host || p != port {
+		verbosef("* Connecting to %s instead of %s\n", net.JoinHostPort(h, p), addr)
+		host, port = h, p
+	}
+
+	addrs, source, ok := r.override(host, port)
+	if ok {
+		verbosef("* Resolved %s to %s from '%s'\n", host, strings.Join(addrs, ", "), source)
+	}
+	return host, port, addrs, nil
+}
+
+// dial connects to the address of the overrides, the dialer resolves the other hosts with the DNS servers
+func (r *hostResolver) dial(ctx context.Context, dial dialFunc, network string, addr string) (net.Conn, error) {
+	host, port, addrs, err := r.route(addr)
+	if err != nil {
+		return nil, err
+	}
+	if addrs == nil {
+		return dial(ctx, network, net.JoinHostPort(host, port))
+	}
+
+	err = fmt.Errorf("no address of '%s' matches the address family", host)
+	for _, a := range addrs {
+		if !isInFamily(a) {
+			continue
+		}
+
+		var conn net.Conn
+		if conn, err = dial(ctx, network, net.JoinHostPort(a, port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// reverse gives the names of an address, the hosts file first
+func (r *hostResolver) reverse(ctx context.Context, addr string) ([]string, error) {
+	if names, ok := r.names[addr]; ok {
+		return names, nil
+	}
+	return r.dns.LookupAddr(ctx, addr)
+}
@@ -0,0 +1,49 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitHostFields(t *testing.T) {
+	tests := []struct {
+		s       string
+		n       int
+		want    []string
+		wantErr bool
+	}{
+		{s: "nn1.example.com:9870:10.0.0.1", n: 3, want: []string{"nn1.example.com", "9870", "10.0.0.1"}},
+		{s: "nn1:9870:[fd00::1]", n: 3, want: []string{"nn1", "9870", "[fd00::1]"}},
+		{s: "[fd00::1]:9870:10.0.0.1", n: 3, want: []string{"fd00::1", "9870", "10.0.0.1"}},
+		{s: "nn1:9870:nn2:9871", n: 4, want: []string{"nn1", "9870", "nn2", "9871"}},
+		{s: "nn1:9870:10.0.0.1,10.0.0.2", n: 3, want: []string{"nn1", "9870", "10.0.0.1,10.0.0.2"}},
+		{s: "nn1:9870:", n: 3, want: []string{"nn1", "9870", ""}},
+		{s: "nn1:9870", n: 3, wantErr: true},
+		{s: "nn1", n: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := splitHostFields(tt.s, tt.n)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitHostFields(%q, %d) error = %v, wantErr %v", tt.s, tt.n, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitHostFields(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
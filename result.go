@@ -0,0 +1,191 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// transferResult is the '--json-result' record of a transfer, one JSON object per line
+type transferResult struct {
+	URL             string              `json:"url"`
+	Method          string              `json:"method"`
+	FinalURL        string              `json:"final_url,omitempty"`
+	Status          int                 `json:"status,omitempty"`
+	HTTPVersion     string              `json:"http_version,omitempty"`
+	Headers         map[string][]string `json:"headers,omitempty"`
+	RemoteAddress   string              `json:"remote_address,omitempty"`
+	Redirects       int                 `json:"num_redirects"`
+	Auth            string              `json:"auth"`
+	TLS             *resultTLS          `json:"tls,omitempty"`
+	Timings         resultTimings       `json:"timings"`
+	BytesUploaded   int64               `json:"bytes_uploaded"`
+	BytesDownloaded int64               `json:"bytes_downloaded"`
+	Body            *string             `json:"body,omitempty"`
+	BodyEncoding    string              `json:"body_encoding,omitempty"`
+	BodyFile        string              `json:"body_file,omitempty"`
+	ExitCode        int                 `json:"exit_code"`
+	ErrorClass      string              `json:"error_class,omitempty"`
+	Error           string              `json:"error,omitempty"`
+}
+
+type resultTLS struct {
+	Version     string     `json:"version"`
+	CipherSuite string     `json:"cipher_suite"`
+	ALPN        string     `json:"alpn,omitempty"`
+	ServerName  string     `json:"server_name,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+	Issuer      string     `json:"issuer,omitempty"`
+	NotAfter    *time.Time `json:"not_after,omitempty"` // a pointer, omitempty never drops a time.Time
+}
+
+// resultTimings are in seconds from the start of the transfer, the same as the '-w' times
+type resultTimings struct {
+	NameLookup    float64 `json:"namelookup"`
+	Connect       float64 `json:"connect"`
+	AppConnect    float64 `json:"appconnect"`
+	SPNEGO        float64 `json:"spnego"`
+	StartTransfer float64 `json:"starttransfer"`
+	Redirect      float64 `json:"redirect"`
+	Total         float64 `json:"total"`
+}
+
+// resultWriter writes the records of the transfers, the parallel ones included
+type resultWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// results is only set while parsing the flags
+var results *resultWriter
+
+func openJSONResult(path string) error {
+	w := io.Writer(os.Stdout)
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("unable to create the JSON result file at: '%s'. Because: %w", path, err)
+		}
+		w = f
+	}
+
+	results = &resultWriter{w: w}
+	return nil
+}
+
+func (r *resultWriter) write(result *transferResult) {
+	line, err := json.Marshal(result)
+	if err != nil {
+		logErrorf("ERROR: unable to encode the JSON result of '%s'. Because: %s\n", result.URL, err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		logErrorf("ERROR: unable to write the JSON result of '%s'. Because: %s\n", result.URL, err)
+	}
+}
+
+func newTransferResult(method httpMethod, url string) *transferResult {
+	return &transferResult{URL: url, Method: string(method), Auth: "none"}
+}
+
+// setResponse fills the result with the final response of the redirect chain
+func (r *transferResult) setResponse(resp *http.Response) {
+	r.FinalURL = redactURL(resp.Request.URL)
+	r.Status = resp.StatusCode
+	r.HTTPVersion = httpVersion(resp)
+
+	r.Headers = map[string][]string{}
+	for k, values := range resp.Header {
+		for _, v := range values {
+			r.Headers[k] = append(r.Headers[k], redactHeader(k, v))
+		}
+	}
+
+	// The scheme of the last request tells which mechanism was really used
+	if scheme, _, _ := strings.Cut(resp.Request.Header.Get("Authorization"), " "); scheme != "" {
+		r.Auth = strings.ToLower(scheme)
+	}
+
+	if resp.TLS != nil {
+		r.TLS = newResultTLS(resp.TLS)
+	}
+}
+
+func newResultTLS(state *tls.ConnectionState) *resultTLS {
+	t := &resultTLS{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		t.Subject, t.Issuer, t.NotAfter = cert.Subject.String(), cert.Issuer.String(), &cert.NotAfter
+	}
+	return t
+}
+
+func (r *transferResult) setStats(s *transferStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.RemoteAddress = s.remoteAddr
+	r.Redirects = s.numRedirects
+	r.BytesUploaded = s.sizeUpload
+	r.BytesDownloaded = s.sizeDownload
+	r.Timings = resultTimings{
+		NameLookup:    s.nameLookup.Seconds(),
+		Connect:       s.connect.Seconds(),
+		AppConnect:    s.appConnect.Seconds(),
+		SPNEGO:        s.spnego.Seconds(),
+		StartTransfer: s.startTransfer.Seconds(),
+		Redirect:      s.redirect.Seconds(),
+		Total:         time.Since(s.start).Seconds(),
+	}
+}
+
+// setBody keeps the text bodies as they are, the binary ones are encoded in base64
+func (r *transferResult) setBody(body *bytes.Buffer) {
+	text := body.String()
+	if !utf8.ValidString(text) {
+		text, r.BodyEncoding = base64.StdEncoding.EncodeToString(body.Bytes()), "base64"
+	}
+	r.Body = &text
+}
+
+func (r *transferResult) setError(err error) {
+	if err == nil {
+		return
+	}
+
+	r.ExitCode = exitCode(err)
+	r.ErrorClass = exitClass(r.ExitCode)
+	r.Error = err.Error()
+}
@@ -0,0 +1,214 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// idempotentMethods can be sent again without side effects (RFC 7231 & RFC 4918)
+var idempotentMethods = []string{"GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE", "PROPFIND", "PROPPATCH"}
+
+// kerberosRetryErrors are fixed by getting a fresh ticket
+var kerberosRetryErrors = []string{"KRB_AP_ERR_SKEW", "KRB_AP_ERR_REPEAT", "Clock skew too great", "Request is a replay"}
+
+// maxRetryBackoff caps the exponential backoff
+const maxRetryBackoff = 2 * time.Minute
+
+func parseRetryStatuses(list string) ([]int, error) {
+	codes := []int{}
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code '%s' in 'retry-on'", s)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// doWithRetry sends the request built by newReq until it succeeds or the retries are exhausted.
+// A new request is built for every attempt, so the body is sent again from the start.
+func doWithRetry(ctx context.Context, client *http.Client, newReq func() (*http.Request, error)) (*http.Response, error) {
+	start := time.Now()
+	freshTicket := false
+
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+
+		// A clock skew or a replayed authenticator gets one retry with a new TGT
+		if isKerberized && !freshTicket && isKerberosRetryable(resp, err) {
+			freshTicket = true
+			discard(resp)
+			logf("WARN: Kerberos rejected the ticket, retrying with a fresh one\n")
+			if err := doKinit(ctx, keytabPath, kerberosPrinciple); err != nil {
+				return nil, withExitCode(exitKerberos, fmt.Errorf("unable to do Kinit. Because: %w", err))
+			}
+			attempt--
+			continue
+		}
+
+		reason := retryReason(req, resp, err)
+		if reason == "" || attempt >= retryCount {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt, resp)
+		if retryMaxTime > 0 && time.Since(start)+delay > retryMaxTime {
+			logf("WARN: not retrying, 'retry-max-time' %s would be exceeded\n", retryMaxTime)
+			return resp, err
+		}
+
+		discard(resp)
+		logf("WARN: %s. Will retry in %s, %d retries left\n", reason, delay.Round(time.Millisecond), retryCount-attempt)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryReason tells why the attempt should be retried, it is empty when it should not be
+func retryReason(req *http.Request, resp *http.Response, err error) string {
+	if !isInSlice(req.Method, idempotentMethods) && !retryNonIdempotent {
+		return ""
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return ""
+	}
+
+	if err != nil {
+		if isTransientError(err) {
+			return "request failed: " + err.Error()
+		}
+		return ""
+	}
+
+	if isInSlice(resp.StatusCode, retryStatuses) {
+		return "server returned status " + resp.Status
+	}
+	return ""
+}
+
+// isTransientError matches the timeouts & the connection errors
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// An unknown host will not show up on the next attempt
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+
+	// TLS alerts are reported as 'remote error' & 'local error'
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op != "remote error" && opErr.Op != "local error" {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func isKerberosRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		for _, e := range kerberosRetryErrors {
+			if strings.Contains(err.Error(), e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	// Read the start of the body, and put it back for the output
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+
+	for _, e := range kerberosRetryErrors {
+		if bytes.Contains(head, []byte(e)) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay honours Retry-After, otherwise backs off exponentially from 'retry-delay' with jitter
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if after := resp.Header.Get("Retry-After"); after != "" {
+			if secs, err := strconv.Atoi(after); err == nil && secs >= 0 {
+				return time.Duration(secs) * time.Second
+			}
+			if at, err := http.ParseTime(after); err == nil {
+				if d := time.Until(at); d > 0 {
+					return d
+				}
+				return 0
+			}
+		}
+	}
+
+	if retryBaseDelay <= 0 {
+		return 0
+	}
+
+	// A shift that lost bits overflowed
+	backoff := retryBaseDelay << uint(attempt)
+	if attempt >= 63 || backoff>>uint(attempt) != retryBaseDelay || backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	// Full jitter on the upper half, so the retries of parallel clients spread out
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func discard(resp *http.Response) {
+	if resp != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
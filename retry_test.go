@@ -0,0 +1,64 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	defer func(saved time.Duration) { retryBaseDelay = saved }(retryBaseDelay)
+
+	retryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": {value}}}
+	}
+
+	tests := []struct {
+		name     string
+		base     time.Duration
+		attempt  int
+		resp     *http.Response
+		min, max time.Duration
+	}{
+		{name: "first retry", base: time.Second, attempt: 0, min: 500 * time.Millisecond, max: time.Second},
+		{name: "doubles", base: time.Second, attempt: 3, min: 4 * time.Second, max: 8 * time.Second},
+		{name: "capped", base: time.Second, attempt: 10, min: maxRetryBackoff / 2, max: maxRetryBackoff},
+		{name: "overflow", base: time.Second, attempt: 40, min: maxRetryBackoff / 2, max: maxRetryBackoff},
+		{name: "huge attempt", base: time.Second, attempt: 100, min: maxRetryBackoff / 2, max: maxRetryBackoff},
+		{name: "zero delay", base: 0, attempt: 5, min: 0, max: 0},
+		{name: "no Retry-After", base: time.Second, attempt: 0, resp: &http.Response{Header: http.Header{}}, min: 500 * time.Millisecond, max: time.Second},
+		{name: "Retry-After seconds", base: time.Second, attempt: 5, resp: retryAfter("3"), min: 3 * time.Second, max: 3 * time.Second},
+		{name: "Retry-After zero", base: time.Second, attempt: 5, resp: retryAfter("0"), min: 0, max: 0},
+		{name: "Retry-After past date", base: time.Second, attempt: 5, resp: retryAfter("Mon, 02 Jan 2006 15:04:05 GMT"), min: 0, max: 0},
+		{
+			name: "Retry-After date", base: time.Second, attempt: 5,
+			resp: retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)),
+			min:  58 * time.Minute, max: time.Hour,
+		},
+		{name: "Retry-After invalid", base: time.Second, attempt: 1, resp: retryAfter("soon"), min: time.Second, max: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		retryBaseDelay = tt.base
+		for i := 0; i < 20; i++ {
+			if got := retryDelay(tt.attempt, tt.resp); got < tt.min || got > tt.max {
+				t.Errorf("%s: retryDelay(%d) = %s, want between %s and %s", tt.name, tt.attempt, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
@@ -20,11 +20,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/user"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jcmturner/gokrb5/v8/client"
 	"github.com/jcmturner/gokrb5/v8/config"
@@ -37,9 +42,14 @@ type Provider interface {
 	SetSPNEGOHeader(*http.Request) error
 }
 
+// krb5 keeps one client, and the service tickets it got, for all the requests.
+// It is shared by the parallel transfers, the client is only replaced when kinit renewed the cache.
 type krb5 struct {
-	cfg *config.Config
-	cl  client.Client
+	mu       sync.Mutex
+	cfg      *config.Config
+	cl       *client.Client
+	ccpath   string
+	ccmodify time.Time
 }
 
 // New constructs OS specific implementation of spnego.Provider interface
@@ -66,8 +76,9 @@ func (k *krb5) makeCfg() error {
 
 	cfg, err := config.Load(cfgPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot load the Kerberos config '%s'. Because: %w", cfgPath, err)
 	}
+	verbosef("* SPNEGO: using the Kerberos config '%s'\n", cfgPath)
 
 	k.cfg = cfg
 	return nil
@@ -86,9 +97,25 @@ func (k *krb5) makeClient() error {
 		ccpath = strings.SplitN(ccname, ":", 2)[1]
 	}
 
+	info, err := os.Stat(ccpath)
+	if err != nil {
+		return fmt.Errorf("cannot load the Kerberos credentials cache '%s'. Because: %w", ccpath, err)
+	}
+	if k.cl != nil && k.ccpath == ccpath && k.ccmodify.Equal(info.ModTime()) {
+		return nil
+	}
+
 	ccache, err := credentials.LoadCCache(ccpath)
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot load the Kerberos credentials cache '%s'. Because: %w", ccpath, err)
+	}
+
+	if k.ccpath != ccpath {
+		k.ccpath = ccpath
+		verbosef("* SPNEGO: using the credentials cache '%s' of '%s@%s'\n", ccpath, ccache.GetClientPrincipalName().PrincipalNameString(), ccache.GetClientRealm())
+		for _, cred := range ccache.GetEntries() {
+			verbosef("*   ticket '%s@%s' expires %s\n", cred.Server.PrincipalName.PrincipalNameString(), cred.Server.Realm, cred.EndTime.Format(time.RFC3339))
+		}
 	}
 
 	client.DisablePAFXFAST(true)
@@ -96,7 +123,7 @@ func (k *krb5) makeClient() error {
 	// create the client from the loaded cache
 	cl, err := client.NewFromCCache(ccache, k.cfg)
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot create the Kerberos client from '%s'. Because: %w", ccpath, err)
 	}
 
 	//
@@ -104,34 +131,42 @@ func (k *krb5) makeClient() error {
 	// client.NewWithKeytab(username string, realm string, kt *keytab.Keytab, krb5conf *config.Config, settings ...func(*Settings))
 
 	//
-	k.cl = *cl
+	k.cl, k.ccmodify = cl, info.ModTime()
 	return nil
 }
 
 func (k *krb5) SetSPNEGOHeader(req *http.Request) error {
-	h, err := canonicalizeHostname(req.URL.Hostname())
+	h, err := canonicalizeHostname(req.Context(), req.URL.Hostname(), portOf(req.URL))
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot canonicalize the hostname '%s' for the SPN. Because: %w", req.URL.Hostname(), err)
 	}
 
+	k.mu.Lock()
 	if err := k.makeCfg(); err != nil {
+		k.mu.Unlock()
 		return err
 	}
-
 	if err := k.makeClient(); err != nil {
+		k.mu.Unlock()
 		return err
 	}
+	cl := k.cl
+	k.mu.Unlock()
 
-	err = spnego.SetSPNEGOHeader(&k.cl, req, "HTTP/"+h)
+	verbosef("* SPNEGO: using the SPN 'HTTP/%s' for '%s'\n", h, req.URL.Host)
+	err = spnego.SetSPNEGOHeader(cl, req, "HTTP/"+h)
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot get a service ticket for 'HTTP/%s'. Because: %w", h, err)
 	}
 
 	return err
 }
 
-func canonicalizeHostname(hostname string) (string, error) {
-	addrs, err := net.LookupHost(hostname)
+// canonicalizeHostname follows the same '--connect-to', '--resolve' & hosts file overrides as the connections,
+// the SPN is for the host really reached. An address without a PTR record keeps the host name.
+func canonicalizeHostname(ctx context.Context, hostname string, port string) (string, error) {
+	hostname, port = resolver.target(hostname, port)
+	addrs, err := resolver.lookup(ctx, hostname, port)
 	if err != nil {
 		return "", err
 	}
@@ -139,8 +174,11 @@ func canonicalizeHostname(hostname string) (string, error) {
 		return hostname, nil
 	}
 
-	names, err := net.LookupAddr(addrs[0])
-	if err != nil {
+	names, err := resolver.reverse(ctx, addrs[0])
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return hostname, nil
+	} else if err != nil {
 		return "", err
 	}
 	if len(names) < 1 {
@@ -0,0 +1,123 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubDNS answers the PTR queries from ptr, any other name does not exist
+func stubDNS(ptr map[string]string) *net.Resolver {
+	answer := func(query []byte) []byte {
+		var msg dnsmessage.Message
+		if err := msg.Unpack(query); err != nil || len(msg.Questions) == 0 {
+			return nil
+		}
+
+		q := msg.Questions[0]
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: msg.ID, Response: true, RCode: dnsmessage.RCodeNameError},
+			Questions: msg.Questions,
+		}
+		if name, ok := ptr[q.Name.String()]; ok && q.Type == dnsmessage.TypePTR {
+			reply.RCode = dnsmessage.RCodeSuccess
+			reply.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(name)},
+			}}
+		}
+		packed, _ := reply.Pack()
+		return packed
+	}
+
+	// The Go resolver speaks the TCP framing over a connection that is not a PacketConn
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go func() {
+				defer server.Close()
+				for {
+					var size [2]byte
+					if _, err := io.ReadFull(server, size[:]); err != nil {
+						return
+					}
+					query := make([]byte, binary.BigEndian.Uint16(size[:]))
+					if _, err := io.ReadFull(server, query); err != nil {
+						return
+					}
+
+					reply := answer(query)
+					binary.BigEndian.PutUint16(size[:], uint16(len(reply)))
+					if _, err := server.Write(append(size[:], reply...)); err != nil {
+						return
+					}
+				}
+			}()
+			return client, nil
+		},
+	}
+}
+
+func TestCanonicalizeHostname(t *testing.T) {
+	defer func(saved *hostResolver) { resolver = saved }(resolver)
+
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	os.WriteFile(hostsFile, []byte("10.20.0.14 nn03.acme.org nn3\n"), 0o644)
+
+	r, err := newHostResolver(
+		[]string{"nn.acme.org:9871:10.20.0.12", "nn-new.acme.org:9871:10.20.0.13"},
+		[]string{"standby.acme.org:9871:nn3:9871"},
+		hostsFile, "",
+	)
+	if err != nil {
+		t.Fatalf("newHostResolver error = %v", err)
+	}
+	r.dns = stubDNS(map[string]string{"12.0.20.10.in-addr.arpa.": "nn02.acme.org."})
+	resolver = r
+
+	tests := []struct {
+		host string
+		port string
+		want string
+	}{
+		// The NameNode pinned behind the alias gives the SPN
+		{host: "nn.acme.org", port: "9871", want: "nn02.acme.org"},
+		// A pinned address without a PTR record keeps the name of the URL
+		{host: "nn-new.acme.org", port: "9871", want: "nn-new.acme.org"},
+		{host: "10.20.0.12", port: "9871", want: "nn02.acme.org"},
+		{host: "nn3", port: "9871", want: "nn03.acme.org"},
+		{host: "standby.acme.org", port: "9871", want: "nn03.acme.org"},
+	}
+
+	for _, tt := range tests {
+		got, err := canonicalizeHostname(context.Background(), tt.host, tt.port)
+		if err != nil {
+			t.Errorf("canonicalizeHostname(%q, %q) error = %v", tt.host, tt.port, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("canonicalizeHostname(%q, %q) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeouts(t *testing.T) {
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow-headers":
+			select {
+			case <-time.After(10 * time.Second):
+			case <-stop:
+			}
+		case "/stalled-body":
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(10 * time.Second):
+			case <-stop:
+			}
+		case "/slow-body":
+			// Slower than the whole of 'max-time', but never idle for 'read-timeout'
+			for i := 0; i < 5; i++ {
+				w.Write([]byte("."))
+				w.(http.Flusher).Flush()
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	// Accepts the connections and never does the TLS handshake
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+
+	// A KDC that never answers, behind kinit
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "klist"), []byte("#!/bin/sh\nexit 0\n"), 0o755)
+	os.WriteFile(filepath.Join(bin, "kinit"), []byte("#!/bin/sh\nexec sleep 10\n"), 0o755)
+	keytab := filepath.Join(bin, "user.keytab")
+	os.WriteFile(keytab, nil, 0o600)
+	krb5Conf := filepath.Join(bin, "krb5.conf")
+	os.WriteFile(krb5Conf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0o600)
+	t.Setenv("KRB5_CONFIG", krb5Conf)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+		// The body received before the timeout is written, how much of it depends on the timing
+		partial bool
+	}{
+		{name: "connect timeout", args: []string{"--connect-timeout", "300ms", "-l", "https://" + silent.Addr().String()}, wantCode: exitTimeout},
+		{name: "max time", args: []string{"-m", "300ms", "-l", srv.URL + "/slow-headers"}, wantCode: exitTimeout},
+		{name: "max time over a slow body", args: []string{"-m", "300ms", "--read-timeout", "5s", "-l", srv.URL + "/slow-body"}, wantCode: exitTimeout, wantStdout: ".....", partial: true},
+		{name: "read timeout", args: []string{"--read-timeout", "300ms", "-l", srv.URL + "/stalled-body"}, wantCode: exitTimeout, wantStdout: "partial"},
+		{name: "slow body within read timeout", args: []string{"--read-timeout", "1s", "-l", srv.URL + "/slow-body"}, wantCode: exitOK, wantStdout: "....."},
+		{name: "kdc timeout", args: []string{"-k", "-kt", keytab, "-kp", "hdfs@EXAMPLE.COM", "--kdc-timeout", "300ms", "-l", srv.URL}, wantCode: exitKerberos, wantStderr: "kinit did not finish in time"},
+		{name: "max time over kinit", args: []string{"-k", "-kt", keytab, "-kp", "hdfs@EXAMPLE.COM", "-m", "300ms", "-l", srv.URL}, wantCode: exitKerberos, wantStderr: "kinit did not finish in time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			stdout, stderr, code := runGurl(t, tt.args...)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("gurl %q took %v", tt.args, elapsed)
+			}
+			if code != tt.wantCode {
+				t.Errorf("gurl %q exited with %d, want %d: %s", tt.args, code, tt.wantCode, stderr)
+			}
+			cut := tt.partial && len(stdout) < len(tt.wantStdout) && strings.HasPrefix(tt.wantStdout, stdout)
+			if stdout != tt.wantStdout && !cut {
+				t.Errorf("gurl %q stdout = %q, want %q", tt.args, stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("gurl %q stderr = %q, want %q in it", tt.args, stderr, tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	netURL "net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redactedHeaders carry credentials, their values are hidden unless '--no-redact' is set
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+var redactedWireHeaders = regexp.MustCompile(`(?im)^((?:proxy-)?authorization|cookie|set-cookie):[^\r\n]*`)
+
+func redactHeader(name, value string) string {
+	if noRedact || !isInSlice(http.CanonicalHeaderKey(name), redactedHeaders) {
+		return value
+	}
+
+	// Keep the auth scheme, it tells which mechanism was used
+	if strings.HasSuffix(http.CanonicalHeaderKey(name), "Authorization") {
+		if scheme, _, found := strings.Cut(value, " "); found {
+			return scheme + " [REDACTED]"
+		}
+	}
+	return "[REDACTED]"
+}
+
+func redactURL(u *netURL.URL) string {
+	if noRedact {
+		return u.String()
+	}
+	return u.Redacted()
+}
+
+// verbosef prints the '-v' messages on stderr, they are not muted by '-s'
+func verbosef(format string, a ...interface{}) {
+	if verboseMode {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
+
+func printHeaders(prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			verbosef("%s %s: %s\n", prefix, k, redactHeader(k, v))
+		}
+	}
+}
+
+// verboseTransport prints the requests as they are sent, after the auth headers were set, and their responses
+type verboseTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (t *verboseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	// The request is printed once the connection is known, with the protocol it negotiated
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				verbosef("* Re-using the connection to %s\n", info.Conn.RemoteAddr())
+			}
+			verbosef("> %s %s %s\n", req.Method, req.URL.RequestURI(), connProto(info.Conn))
+			verbosef("> Host: %s\n", host)
+			printHeaders(">", req.Header)
+			verbosef(">\n")
+		},
+	}))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		verbosef("* Request to '%s' failed: %s\n", redactURL(req.URL), err)
+		return resp, err
+	}
+
+	verbosef("< %s %s\n", resp.Proto, resp.Status)
+	printHeaders("<", resp.Header)
+	verbosef("<\n")
+
+	return resp, nil
+}
+
+func connProto(conn net.Conn) string {
+	// quic-go reports its connections with their UDP addresses
+	if _, ok := conn.RemoteAddr().(*net.UDPAddr); ok {
+		return "HTTP/3"
+	}
+
+	if cs, ok := conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		if cs.ConnectionState().NegotiatedProtocol == "h2" {
+			return "HTTP/2"
+		}
+		return "HTTP/1.1"
+	}
+
+	if http2PriorKnowledge {
+		return "HTTP/2"
+	}
+	return "HTTP/1.1"
+}
+
+// verboseTrace prints the connection & TLS events of the requests made with the context
+func verboseTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err != nil {
+				verbosef("* Could not resolve the host: %s\n", info.Err)
+				return
+			}
+			addrs := []string{}
+			for _, a := range info.Addrs {
+				addrs = append(addrs, a.String())
+			}
+			verbosef("* Resolved to %s\n", strings.Join(addrs, ", "))
+		},
+		ConnectStart: func(network, addr string) {
+			verbosef("* Trying %s (%s)...\n", addr, network)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				verbosef("* Connection to %s failed: %s\n", addr, err)
+				return
+			}
+			verbosef("* Connected to %s\n", addr)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err != nil {
+				verbosef("* TLS handshake failed: %s\n", err)
+				return
+			}
+			printTLSState(state)
+		},
+	})
+}
+
+func printTLSState(state tls.ConnectionState) {
+	alpn := state.NegotiatedProtocol
+	if alpn == "" {
+		alpn = "none"
+	}
+	verbosef("* TLS handshake done: %s, %s, ALPN: %s\n", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), alpn)
+
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		verbosef("*   subject: %s\n", cert.Subject)
+		verbosef("*   issuer: %s\n", cert.Issuer)
+		verbosef("*   valid: %s to %s\n", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+		if len(cert.DNSNames) > 0 {
+			verbosef("*   names: %s\n", strings.Join(cert.DNSNames, ", "))
+		}
+	}
+}
+
+// wireTracer dumps the bytes going over the connections for '--trace' & '--trace-ascii'.
+// HTTPS connections are dumped after the decryption.
+type wireTracer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	ascii bool
+}
+
+// tracer is only set while parsing the flags
+var tracer *wireTracer
+
+func openWireTrace(path string, ascii bool) error {
+	w := io.Writer(os.Stdout)
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("unable to create the trace file at: '%s'. Because: %w", path, err)
+		}
+		w = f
+	}
+
+	tracer = &wireTracer{w: w, ascii: ascii}
+	return nil
+}
+
+func (t *wireTracer) dump(event string, data []byte) {
+	if !noRedact {
+		data = redactedWireHeaders.ReplaceAll(data, []byte("$1: [REDACTED]"))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintf(t.w, "%s %s, %d bytes (0x%x)\n", time.Now().Format("15:04:05.000000"), event, len(data), len(data))
+
+	if t.ascii {
+		offset := 0
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if line != "" {
+				fmt.Fprintf(t.w, "%04x: %s\n", offset, strings.TrimRight(line, "\r\n"))
+			}
+			offset += len(line)
+		}
+		return
+	}
+
+	for offset := 0; offset < len(data); offset += 16 {
+		end := offset + 16
+		if end > len(data) {
+			end = len(data)
+		}
+
+		hex := strings.Builder{}
+		text := strings.Builder{}
+		for i := offset; i < offset+16; i++ {
+			if i >= end {
+				hex.WriteString("   ")
+				continue
+			}
+			fmt.Fprintf(&hex, "%02x ", data[i])
+			if c := data[i]; c >= 0x20 && c < 0x7f {
+				text.WriteByte(c)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(t.w, "%04x: %s%s\n", offset, hex.String(), text.String())
+	}
+}
+
+func (t *wireTracer) info(format string, a ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "%s == Info: %s\n", time.Now().Format("15:04:05.000000"), fmt.Sprintf(format, a...))
+}
+
+type tracedConn struct {
+	net.Conn
+	tracer *wireTracer
+}
+
+// ConnectionState lets the transport see the ALPN of a traced TLS connection, h2 is only used when it knows
+func (c *tracedConn) ConnectionState() tls.ConnectionState {
+	if tc, ok := c.Conn.(*tls.Conn); ok {
+		return tc.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
+
+func (c *tracedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.tracer.dump("<= Recv data", p[:n])
+	}
+	return n, err
+}
+
+func (c *tracedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.tracer.dump("=> Send data", p[:n])
+	}
+	return n, err
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (t *wireTracer) wrapDial(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		t.info("Connected to %s (%s)", addr, conn.RemoteAddr())
+		return &tracedConn{Conn: conn, tracer: t}, nil
+	}
+}
+
+// wrapDialTLS does the TLS handshake itself, so the traced bytes are the decrypted ones.
+// The transport still calls the TLSHandshakeDone hook of '-v' & '-w' for the connections of DialTLSContext
+// that have a ConnectionState method, tracedConn has one so the TLS summary is printed with '-v'.
+func (t *wireTracer) wrapDialTLS(dial dialFunc, config *tls.Config) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		raw, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := config.Clone()
+		if cfg.ServerName == "" {
+			host, _, _ := net.SplitHostPort(addr)
+			cfg.ServerName = host
+		}
+
+		conn := tls.Client(raw, cfg)
+		if err := conn.HandshakeContext(ctx); err != nil {
+			raw.Close()
+			return nil, err
+		}
+
+		state := conn.ConnectionState()
+		t.info("TLS connection to %s using %s, %s", addr, tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+		return &tracedConn{Conn: conn, tracer: t}, nil
+	}
+}
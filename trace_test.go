@@ -0,0 +1,132 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// hexDumpLine is a line of the '--trace' dump: the offset, 16 bytes in hex, then the text
+var hexDumpLine = regexp.MustCompile(`^[0-9a-f]{4}: ((?:[0-9a-f]{2} )+)`)
+
+// undumpHex gives back the bytes of a '--trace' dump
+func undumpHex(dump string) string {
+	var data []byte
+	for _, line := range strings.Split(dump, "\n") {
+		m := hexDumpLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		b, err := hex.DecodeString(strings.ReplaceAll(m[1], " ", ""))
+		if err == nil {
+			data = append(data, b...)
+		}
+	}
+	return string(data)
+}
+
+func TestVerboseRedaction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "token", Value: "s3cret-set-cookie"})
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	basic := base64.StdEncoding.EncodeToString([]byte("hdfs:s3cret-password"))
+	secrets := []string{basic, "s3cret-password", "s3cret-cookie", "s3cret-set-cookie"}
+	request := []string{"-u", "hdfs:s3cret-password", "-H", "Cookie: session=s3cret-cookie", "-l", srv.URL + "/path"}
+
+	trace := filepath.Join(t.TempDir(), "trace.txt")
+	tests := []struct {
+		name     string
+		args     []string
+		trace    func(string) string
+		want     []string
+		redacted bool
+	}{
+		{
+			name:     "verbose",
+			args:     []string{"-v"},
+			want:     []string{"> GET /path HTTP/1.1\n", "> Authorization: Basic [REDACTED]\n", "> Cookie: [REDACTED]\n", "< HTTP/1.1 200 OK\n", "< Set-Cookie: [REDACTED]\n"},
+			redacted: true,
+		},
+		{
+			name: "verbose no-redact",
+			args: []string{"-v", "--no-redact"},
+			want: []string{"> Authorization: Basic " + basic + "\n", "> Cookie: session=s3cret-cookie\n", "< Set-Cookie: token=s3cret-set-cookie\n"},
+		},
+		{
+			name:     "trace-ascii",
+			args:     []string{"--trace-ascii", trace},
+			trace:    func(dump string) string { return dump },
+			want:     []string{"GET /path HTTP/1.1", "Authorization: [REDACTED]", "Cookie: [REDACTED]", "Set-Cookie: [REDACTED]", "== Info: Connected to "},
+			redacted: true,
+		},
+		{
+			name:  "trace-ascii no-redact",
+			args:  []string{"--trace-ascii", trace, "--no-redact"},
+			trace: func(dump string) string { return dump },
+			want:  []string{"Authorization: Basic " + basic, "Set-Cookie: token=s3cret-set-cookie"},
+		},
+		{
+			name:     "trace",
+			args:     []string{"--trace", trace},
+			trace:    undumpHex,
+			want:     []string{"GET /path HTTP/1.1\r\n", "Authorization: [REDACTED]\r\n", "Cookie: [REDACTED]\r\n", "Set-Cookie: [REDACTED]\r\n", "\r\n\r\nok"},
+			redacted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(trace)
+			stdout, stderr, code := runGurl(t, append(tt.args, request...)...)
+			if code != exitOK {
+				t.Fatalf("gurl exited with %d: %s", code, stderr)
+			}
+			if stdout != "ok" {
+				t.Errorf("stdout = %q, want the body only", stdout)
+			}
+
+			got := stderr
+			if tt.trace != nil {
+				dump, err := os.ReadFile(trace)
+				if err != nil {
+					t.Fatalf("no trace file. Because: %v", err)
+				}
+				got = tt.trace(string(dump))
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output has no %q:\n%s", want, got)
+				}
+			}
+			for _, secret := range secrets {
+				if tt.redacted && strings.Contains(got, secret) {
+					t.Errorf("output shows the secret %q:\n%s", secret, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	netURL "net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxGlobURLs stops a typo like '[1-999999]' from queuing a million requests
+const maxGlobURLs = 100000
+
+// transfer is a single URL to fetch, with its HA endpoints and its own '-o' path
+type transfer struct {
+	url       string
+	endpoints []*netURL.URL
+	output    string
+}
+
+var (
+	globNumericRange = regexp.MustCompile(`^([0-9]+)-([0-9]+)(?::([0-9]+))?$`)
+	globAlphaRange   = regexp.MustCompile(`^([a-zA-Z])-([a-zA-Z])(?::([0-9]+))?$`)
+	outputGlobRef    = regexp.MustCompile(`#([0-9]+)`)
+)
+
+// collectTransfers expands every '-l' & '--url-file' entry into the transfers to run
+func collectTransfers(specs []string, urlFile, output, hdfsSite string) ([]transfer, error) {
+	if urlFile != "" {
+		lines, err := readURLFile(urlFile)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, lines...)
+	}
+
+	transfers := []transfer{}
+	outputs := map[string]string{}
+	for _, spec := range specs {
+		spec = withBaseURL(spec)
+		matches := []globMatch{{url: spec}}
+		if !globOff {
+			var err error
+			if matches, err = expandGlob(spec); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, m := range matches {
+			u, endpoints, err := resolveEndpoints(m.url, hdfsSite)
+			if err != nil {
+				return nil, fmt.Errorf("invalid url '%s'. Because: %w", m.url, err)
+			}
+
+			out, err := expandOutput(output, m.values)
+			if err != nil {
+				return nil, err
+			}
+			if prev, found := outputs[out]; found && out != "" && out != os.DevNull {
+				return nil, fmt.Errorf("'%s' & '%s' would both be written to '%s', use '#1', '#2'... of the URL globs in the 'output-file'", prev, u, out)
+			}
+			outputs[out] = u
+
+			transfers = append(transfers, transfer{url: u, endpoints: endpoints, output: out})
+			if len(transfers) > maxGlobURLs {
+				return nil, fmt.Errorf("more than %d URLs to fetch", maxGlobURLs)
+			}
+		}
+	}
+
+	if len(transfers) == 0 {
+		return nil, fmt.Errorf("no URL to fetch")
+	}
+	return transfers, nil
+}
+
+// readURLFile reads one URL per line, blank lines & '#' comments are skipped. '-' reads stdin.
+func readURLFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the URL file '%s'. Because: %w", path, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	urls := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the URL file '%s'. Because: %w", path, err)
+	}
+	return urls, nil
+}
+
+// globMatch is one URL of a glob with the value picked for each of its patterns
+type globMatch struct {
+	url    string
+	values []string
+}
+
+// expandGlob expands the cURL style '[01-40]', '[a-z]', '[0-100:10]' & '{nn1,nn2}' patterns, the first one varying the slowest.
+// Brackets & braces that are not a pattern, like an IPv6 address, are kept as they are, '\[' & '\{' escape them.
+func expandGlob(raw string) ([]globMatch, error) {
+	matches := []globMatch{{}}
+
+	literal := strings.Builder{}
+	appendLiteral := func() {
+		for i := range matches {
+			matches[i].url += literal.String()
+		}
+		literal.Reset()
+	}
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == '\\' && i+1 < len(raw) && (raw[i+1] == '[' || raw[i+1] == '{') {
+			literal.WriteByte(raw[i+1])
+			i++
+			continue
+		}
+
+		closing := map[byte]byte{'[': ']', '{': '}'}[c]
+		end := -1
+		if closing != 0 {
+			end = strings.IndexByte(raw[i+1:], closing)
+		}
+		if end < 0 {
+			literal.WriteByte(c)
+			continue
+		}
+
+		set, err := parseGlobSet(c, raw[i+1:i+1+end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob in the url '%s'. Because: %w", raw, err)
+		}
+		if set == nil {
+			literal.WriteByte(c)
+			continue
+		}
+
+		if len(matches)*len(set) > maxGlobURLs {
+			return nil, fmt.Errorf("the url '%s' expands to more than %d URLs", raw, maxGlobURLs)
+		}
+
+		appendLiteral()
+		expanded := make([]globMatch, 0, len(matches)*len(set))
+		for _, m := range matches {
+			for _, v := range set {
+				values := append(append([]string{}, m.values...), v)
+				expanded = append(expanded, globMatch{url: m.url + v, values: values})
+			}
+		}
+		matches = expanded
+		i += end + 1
+	}
+
+	appendLiteral()
+	return matches, nil
+}
+
+// parseGlobSet returns the values of a pattern, nil when the text is not one
+func parseGlobSet(open byte, body string) ([]string, error) {
+	if open == '{' {
+		if !strings.Contains(body, ",") {
+			return nil, nil
+		}
+		return strings.Split(body, ","), nil
+	}
+
+	if m := globNumericRange.FindStringSubmatch(body); m != nil {
+		from, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, err
+		}
+		step, err := globStep(m[3], from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		// '[01-40]' keeps the width of the first number
+		width := 0
+		if len(m[1]) > 1 && m[1][0] == '0' {
+			width = len(m[1])
+		}
+
+		set := []string{}
+		for n := from; n <= to; n += step {
+			set = append(set, fmt.Sprintf("%0*d", width, n))
+			if len(set) > maxGlobURLs {
+				return nil, fmt.Errorf("'[%s]' has more than %d values", body, maxGlobURLs)
+			}
+		}
+		return set, nil
+	}
+
+	if m := globAlphaRange.FindStringSubmatch(body); m != nil {
+		from, to := int(m[1][0]), int(m[2][0])
+		step, err := globStep(m[3], from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		set := []string{}
+		for c := from; c <= to; c += step {
+			set = append(set, string(rune(c)))
+		}
+		return set, nil
+	}
+
+	return nil, nil
+}
+
+func globStep(raw string, from, to int) (int, error) {
+	if from > to {
+		return 0, fmt.Errorf("the range %d-%d is reversed", from, to)
+	}
+	if raw == "" {
+		return 1, nil
+	}
+
+	step, err := strconv.Atoi(raw)
+	if err != nil || step < 1 {
+		return 0, fmt.Errorf("invalid step '%s'", raw)
+	}
+	return step, nil
+}
+
+// expandOutput replaces '#N' in the '-o' path with the value of the Nth glob pattern of the URL
+func expandOutput(output string, values []string) (string, error) {
+	var err error
+	expanded := outputGlobRef.ReplaceAllStringFunc(output, func(ref string) string {
+		n, _ := strconv.Atoi(ref[1:])
+		if n < 1 || n > len(values) {
+			err = fmt.Errorf("'output-file' uses '%s' but the URL has %d glob patterns", ref, len(values))
+			return ref
+		}
+		return values[n-1]
+	})
+	return expanded, err
+}
+
+// runTransfers fetches the URLs with one client, so the connections & the Kerberos tickets are shared.
+// With '--parallel' up to 'parallel-max' transfers run at once, each response is buffered
+// and written to stdout in one piece once complete so the outputs do not interleave.
+func runTransfers(ctx context.Context, method httpMethod, transfers []transfer) error {
+	client := newClient()
+
+	workers := 1
+	if parallelMode {
+		workers = min(parallelMax, len(transfers))
+	}
+
+	var (
+		mu      sync.Mutex
+		failed  int
+		lastErr error
+		wg      sync.WaitGroup
+	)
+
+	jobs := make(chan transfer)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				var buf bytes.Buffer
+				var w io.Writer = os.Stdout
+				if workers > 1 {
+					w = &buf
+				}
+
+				_, status, err := makeRequest(ctx, client, method, t, w)
+
+				mu.Lock()
+				os.Stdout.Write(buf.Bytes())
+				if err != nil {
+					failed, lastErr = failed+1, err
+					if status != 0 {
+						logErrorf("ERROR: '%s' failed with status: %d. Because: %s\n", t.url, status, err)
+					} else {
+						logErrorf("ERROR: '%s' failed. Because: %s\n", t.url, err)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, t := range transfers {
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Like curl, the exit code is the one of the last failed transfer
+	if failed > 0 {
+		return withExitCode(exitCode(lastErr), fmt.Errorf("%d of the %d transfers failed", failed, len(transfers)))
+	}
+	return nil
+}
+
+// withFailover returns a copy of the client trying each HA endpoint of a transfer in turn
+func withFailover(client *http.Client, endpoints []*netURL.URL) *http.Client {
+	if len(endpoints) < 2 {
+		return client
+	}
+
+	c := *client
+	c.Transport = newFailoverTransport(c.Transport, endpoints)
+	return &c
+}
@@ -0,0 +1,126 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandGlob(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []globMatch
+		wantErr bool
+	}{
+		{raw: "http://nn1:9870/jmx", want: []globMatch{{url: "http://nn1:9870/jmx"}}},
+		{
+			raw: "http://{nn1,nn2}:9870/jmx",
+			want: []globMatch{
+				{url: "http://nn1:9870/jmx", values: []string{"nn1"}},
+				{url: "http://nn2:9870/jmx", values: []string{"nn2"}},
+			},
+		},
+		{
+			raw: "http://dn[1-2]/logs/{a,b}.log",
+			want: []globMatch{
+				{url: "http://dn1/logs/a.log", values: []string{"1", "a"}},
+				{url: "http://dn1/logs/b.log", values: []string{"1", "b"}},
+				{url: "http://dn2/logs/a.log", values: []string{"2", "a"}},
+				{url: "http://dn2/logs/b.log", values: []string{"2", "b"}},
+			},
+		},
+		{raw: "http://[::1]:8080/", want: []globMatch{{url: "http://[::1]:8080/"}}},
+		{raw: `http://h/\[1-2]`, want: []globMatch{{url: "http://h/[1-2]"}}},
+		{raw: "http://h/{single}", want: []globMatch{{url: "http://h/{single}"}}},
+		{raw: "http://h/[1-2", want: []globMatch{{url: "http://h/[1-2"}}},
+		{raw: "http://h/[5-1]", wantErr: true},
+		{raw: "http://h/[0-999]/[0-999]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := expandGlob(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandGlob(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandGlob(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseGlobSet(t *testing.T) {
+	tests := []struct {
+		open    byte
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{open: '{', body: "nn1,nn2,nn3", want: []string{"nn1", "nn2", "nn3"}},
+		{open: '{', body: "a,", want: []string{"a", ""}},
+		{open: '{', body: "nn1", want: nil},
+		{open: '[', body: "1-3", want: []string{"1", "2", "3"}},
+		{open: '[', body: "08-11", want: []string{"08", "09", "10", "11"}},
+		{open: '[', body: "0-100:25", want: []string{"0", "25", "50", "75", "100"}},
+		{open: '[', body: "a-e:2", want: []string{"a", "c", "e"}},
+		{open: '[', body: "X-Z", want: []string{"X", "Y", "Z"}},
+		{open: '[', body: "::1", want: nil},
+		{open: '[', body: "a-9", want: nil},
+		{open: '[', body: "3-1", wantErr: true},
+		{open: '[', body: "z-a", wantErr: true},
+		{open: '[', body: "1-3:0", wantErr: true},
+		{open: '[', body: "0-999999", wantErr: true},
+		{open: '[', body: "1-99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseGlobSet(tt.open, tt.body)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGlobSet(%q, %q) error = %v, wantErr %v", tt.open, tt.body, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseGlobSet(%q, %q) = %q, want %q", tt.open, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestExpandOutput(t *testing.T) {
+	tests := []struct {
+		output  string
+		values  []string
+		want    string
+		wantErr bool
+	}{
+		{output: "jmx.json", want: "jmx.json"},
+		{output: "jmx-#1.json", values: []string{"nn1"}, want: "jmx-nn1.json"},
+		{output: "#2/#1-#2.log", values: []string{"a", "dn1"}, want: "dn1/a-dn1.log"},
+		{output: "#10.log", values: []string{"a"}, wantErr: true},
+		{output: "#0.log", values: []string{"a"}, wantErr: true},
+		{output: "#2.log", values: []string{"a"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := expandOutput(tt.output, tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandOutput(%q, %q) error = %v, wantErr %v", tt.output, tt.values, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("expandOutput(%q, %q) = %q, want %q", tt.output, tt.values, got, tt.want)
+		}
+	}
+}
@@ -14,12 +14,19 @@
 
 package main
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
 
-// spnegoTransport extends the native http.Transport to provide SPNEGO communication
+// spnegoTransport wraps the native http.Transport to provide SPNEGO communication
 type spnegoTransport struct {
-	http.Transport
-	spnego Provider
+	Transport http.RoundTripper
+	spnego    Provider
 }
 
 // Error is used to distinguish errors from underlying libraries (gokrb5 or sspi).
@@ -34,14 +41,88 @@ func (e *Error) Error() string {
 
 // RoundTrip implements the RoundTripper interface.
 func (t *spnegoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if t.spnego == nil {
-		t.spnego = New()
+	// RoundTrip must not modify the callers request
+	r := req.Clone(req.Context())
+	if err := t.setSPNEGOHeader(r); err != nil {
+		return nil, err
 	}
 
-	if err := t.spnego.SetSPNEGOHeader(req); err != nil {
-		return nil, &Error{Err: err}
+	resp, err := t.Transport.RoundTrip(r)
+	if err != nil || !isNegotiateChallenge(resp) {
+		return resp, err
 	}
 
-	return t.Transport.RoundTrip(req)
+	// The server rejected the token, retry once with a fresh one.
+	// This is only possible when the body can be sent again.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	r = req.Clone(req.Context())
+	if req.GetBody != nil {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := t.setSPNEGOHeader(r); err != nil {
+		return nil, err
+	}
+
+	return t.Transport.RoundTrip(r)
 	// ToDo: process negotiate token from response
 }
+
+// setSPNEGOHeader accounts the KDC exchange in the '-w' timings
+func (t *spnegoTransport) setSPNEGOHeader(req *http.Request) error {
+	start := time.Now()
+	err := setSPNEGOHeaderInTime(t.spnego, req)
+	if stats := statsFromContext(req.Context()); stats != nil {
+		stats.addSPNEGO(time.Since(start))
+	}
+	return err
+}
+
+// setSPNEGOHeaderInTime bounds the KDC exchange with 'kdc-timeout' & the request context
+func setSPNEGOHeaderInTime(spnego Provider, req *http.Request) error {
+	ctx := req.Context()
+	if kdcTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, kdcTimeout)
+		defer cancel()
+	}
+
+	// gokrb5 has no context support, the exchange is abandoned when the context is done
+	done := make(chan error, 1)
+	go func() {
+		done <- spnego.SetSPNEGOHeader(req)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("the Kerberos exchange did not finish in time. Because: %w", ctx.Err())
+	}
+
+	if err != nil {
+		return &Error{Err: err}
+	}
+	return nil
+}
+
+func isNegotiateChallenge(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	for _, v := range resp.Header.Values("WWW-Authenticate") {
+		if strings.HasPrefix(strings.TrimSpace(v), "Negotiate") {
+			return true
+		}
+	}
+	return false
+}
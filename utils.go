@@ -16,7 +16,7 @@ package main
 
 import (
 	"bytes"
-	"fmt"
+	"errors"
 	"os"
 	"os/exec"
 	"strings"
@@ -42,24 +42,34 @@ func removeFromSlice[T comparable](s T, l []T) []T {
 	return result
 }
 
+func countTrue(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
 func isKRBDepsAvail() error {
 	//
 	deps := []string{"kinit", "klist", "awk", "grep", "head"}
 	krbConfigFromEnv := strings.TrimSpace(os.Getenv("KRB5_CONFIG"))
 
 	if _, err := os.Stat(defaultShell); err != nil {
-		return fmt.Errorf("cannot find or access '" + defaultShell + "' because " + err.Error())
+		return errors.New("cannot find or access '" + defaultShell + "' because " + err.Error())
 	}
 
 	if krbConfigFromEnv == "" {
 		if _, err := os.Stat(defaultKRBConfig); err != nil {
 			if _, err := os.Stat(secondaryKRBConfig); err != nil {
-				return fmt.Errorf("cannot find or access '" + defaultKRBConfig + "' OR '" + secondaryKRBConfig + "' and the ENV variable '" + krbConfigFromEnv + "' is empty")
+				return errors.New("cannot find or access '" + defaultKRBConfig + "' OR '" + secondaryKRBConfig + "' and the ENV variable '" + krbConfigFromEnv + "' is empty")
 			}
 		}
 	} else {
 		if _, err := os.Stat(krbConfigFromEnv); err != nil {
-			return fmt.Errorf("got custom KRB config path from the ENV variable '" + krbConfigFromEnv + "' and is not accessible because " + err.Error())
+			return errors.New("got custom KRB config path from the ENV variable '" + krbConfigFromEnv + "' and is not accessible because " + err.Error())
 		}
 	}
 
@@ -71,13 +81,31 @@ func isKRBDepsAvail() error {
 		tryKlist.Stdout = &outb
 		tryKlist.Stderr = &errb
 		if err := tryKlist.Run(); err != nil {
-			return fmt.Errorf("cannot check for the dependency binary '" + dep + "' using the command '" + fmt.Sprintf(defaultShell+" -c"+" which "+dep) + "' because " + errb.String() + err.Error())
+			return errors.New("cannot check for the dependency binary '" + dep + "' using the command '" + defaultShell + " -c" + " which " + dep + "' because " + errb.String() + err.Error())
 		}
 
 		//
 		if strings.TrimSpace(outb.String()) == "" {
-			return fmt.Errorf("cannot find the binary '" + dep + "' in the OS path")
+			return errors.New("cannot find the binary '" + dep + "' in the OS path")
 		}
 	}
 	return nil
 }
+
+// isToken reports whether s is a valid RFC 7230 token, the grammar used
+// for both HTTP methods and header field names
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
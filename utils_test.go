@@ -0,0 +1,40 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestIsToken(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"GET", true},
+		{"X-Custom_Header.v2", true},
+		{"!#$%&'*+-.^_`|~", true},
+		{"", false},
+		{"BAD METHOD", false},
+		{"Name:", false},
+		{"a\r\nb", false},
+		{"(comment)", false},
+		{"héllo", false},
+	}
+
+	for _, tt := range tests {
+		if got := isToken(tt.s); got != tt.want {
+			t.Errorf("isToken(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
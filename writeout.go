@@ -0,0 +1,261 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type statsKey struct{}
+
+// transferStats collects the metrics of a request for '-w'.
+// The durations are measured from the start of the request, like cURL does,
+// and cover the whole redirect chain.
+type transferStats struct {
+	mu sync.Mutex
+
+	start         time.Time
+	nameLookup    time.Duration
+	connect       time.Duration
+	appConnect    time.Duration
+	spnego        time.Duration
+	startTransfer time.Duration
+	redirect      time.Duration
+	total         time.Duration
+
+	numRedirects int
+	sizeDownload int64
+	sizeUpload   int64
+	remoteAddr   string
+}
+
+func newTransferStats() *transferStats {
+	return &transferStats{start: time.Now()}
+}
+
+// attach returns a context carrying the stats and the trace hooks feeding them
+func (s *transferStats) attach(ctx context.Context) context.Context {
+	since := func(d *time.Duration) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		*d = time.Since(s.start)
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSDone: func(httptrace.DNSDoneInfo) { since(&s.nameLookup) },
+		ConnectDone: func(_, addr string, err error) {
+			if err == nil {
+				since(&s.connect)
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				since(&s.appConnect)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.remoteAddr = info.Conn.RemoteAddr().String()
+		},
+		GotFirstResponseByte: func() { since(&s.startTransfer) },
+	}
+
+	return httptrace.WithClientTrace(context.WithValue(ctx, statsKey{}, s), trace)
+}
+
+func statsFromContext(ctx context.Context) *transferStats {
+	s, _ := ctx.Value(statsKey{}).(*transferStats)
+	return s
+}
+
+// addSPNEGO accounts the time spent getting the service ticket from the KDC & building the token
+func (s *transferStats) addSPNEGO(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spnego += d
+}
+
+// addRedirect is called before following a redirect
+func (s *transferStats) addRedirect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.numRedirects++
+	s.redirect = time.Since(s.start)
+}
+
+func (s *transferStats) countBody(body io.ReadCloser) io.ReadCloser {
+	return &countingReader{ReadCloser: body, n: &s.sizeDownload}
+}
+
+// countUpload counts the bytes of the request body, a body sent again is counted from 0
+func (s *transferStats) countUpload(getBody func() (io.ReadCloser, error)) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		body, err := getBody()
+		if err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		s.sizeUpload = 0
+		s.mu.Unlock()
+		return &countingReader{ReadCloser: body, n: &s.sizeUpload}, nil
+	}
+}
+
+func (s *transferStats) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total = time.Since(s.start)
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// loadWriteOut reads the format from a file for '@path', '@-' reads it from stdin
+func loadWriteOut(format string) (string, error) {
+	if !strings.HasPrefix(format, "@") {
+		return format, nil
+	}
+
+	path := strings.TrimPrefix(format, "@")
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("unable to read the write-out format from '%s'. Because: %w", path, err)
+	}
+	return string(raw), nil
+}
+
+// writeOut expands the cURL style '-w' format.
+// '%{variable}' is replaced by its value, '\n', '\r', '\t' & '\\' are escapes and '%%' is a '%'.
+func writeOut(w io.Writer, format string, resp *http.Response, s *transferStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seconds := func(d time.Duration) string {
+		return strconv.FormatFloat(d.Seconds(), 'f', 6, 64)
+	}
+
+	vars := map[string]string{
+		"http_code":          strconv.Itoa(resp.StatusCode),
+		"response_code":      strconv.Itoa(resp.StatusCode),
+		"http_version":       httpVersion(resp),
+		"content_type":       resp.Header.Get("Content-Type"),
+		"size_download":      strconv.FormatInt(s.sizeDownload, 10),
+		"size_upload":        strconv.FormatInt(s.sizeUpload, 10),
+		"num_redirects":      strconv.Itoa(s.numRedirects),
+		"redirect_url":       "",
+		"url_effective":      resp.Request.URL.String(),
+		"remote_ip":          "",
+		"remote_port":        "",
+		"time_namelookup":    seconds(s.nameLookup),
+		"time_connect":       seconds(s.connect),
+		"time_appconnect":    seconds(s.appConnect),
+		"time_spnego":        seconds(s.spnego),
+		"time_starttransfer": seconds(s.startTransfer),
+		"time_redirect":      seconds(s.redirect),
+		"time_total":         seconds(s.total),
+	}
+
+	// Only set when the redirect was not followed
+	if resp.StatusCode >= 300 && resp.StatusCode <= 399 {
+		if location, err := resp.Location(); err == nil {
+			vars["redirect_url"] = location.String()
+		}
+	}
+
+	if i := strings.LastIndex(s.remoteAddr, ":"); i > 0 {
+		vars["remote_ip"] = strings.Trim(s.remoteAddr[:i], "[]")
+		vars["remote_port"] = s.remoteAddr[i+1:]
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '%' && strings.HasPrefix(format[i:], "%%"):
+			out.WriteByte('%')
+			i++
+		case c == '%' && strings.HasPrefix(format[i:], "%{"):
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				out.WriteString(format[i:])
+				i = len(format)
+				continue
+			}
+
+			name := format[i+2 : i+end]
+			if v, ok := vars[name]; ok {
+				out.WriteString(v)
+			} else {
+				logf("WARN: unknown --write-out variable '%s'\n", name)
+			}
+			i += end
+		case c == '\\' && i+1 < len(format):
+			switch format[i+1] {
+			case 'n':
+				out.WriteByte('\n')
+			case 'r':
+				out.WriteByte('\r')
+			case 't':
+				out.WriteByte('\t')
+			case '\\':
+				out.WriteByte('\\')
+			default:
+				out.WriteByte(c)
+				continue
+			}
+			i++
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// httpVersion is '1.1', '2' or '3' like cURL reports it
+func httpVersion(resp *http.Response) string {
+	if resp.ProtoMajor >= 2 {
+		return strconv.Itoa(resp.ProtoMajor)
+	}
+	return strings.TrimPrefix(resp.Proto, "HTTP/")
+}
@@ -0,0 +1,86 @@
+// Acceldata Inc. and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// 	Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	netURL "net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteOut(t *testing.T) {
+	// Mute the warning of the unknown variable
+	defer func(saved bool) { silentMode = saved }(silentMode)
+	silentMode = true
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Request:    &http.Request{URL: &netURL.URL{Scheme: "http", Host: "nn1:9870", Path: "/jmx"}},
+	}
+	redirect := &http.Response{
+		StatusCode: http.StatusTemporaryRedirect,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     http.Header{"Location": {"http://dn1:9864/webhdfs/v1/a"}},
+		Request:    &http.Request{URL: &netURL.URL{Scheme: "http", Host: "nn1:9870", Path: "/webhdfs/v1/a"}},
+	}
+
+	stats := func() *transferStats {
+		return &transferStats{
+			connect:      1500 * time.Microsecond,
+			total:        2 * time.Second,
+			numRedirects: 1,
+			sizeDownload: 42,
+			sizeUpload:   7,
+			remoteAddr:   "[::1]:9870",
+		}
+	}
+
+	tests := []struct {
+		format string
+		resp   *http.Response
+		want   string
+	}{
+		{format: "%{http_code}\\n", resp: resp, want: "200\n"},
+		{format: "%{response_code} %{http_version}", resp: resp, want: "200 1.1"},
+		{format: "%{http_version} %{redirect_url}", resp: redirect, want: "2 http://dn1:9864/webhdfs/v1/a"},
+		{format: "%{redirect_url}", resp: resp, want: ""},
+		{format: "%{content_type}", resp: resp, want: "application/json"},
+		{format: "%{url_effective}", resp: resp, want: "http://nn1:9870/jmx"},
+		{format: "%{size_download}/%{size_upload} %{num_redirects}", resp: resp, want: "42/7 1"},
+		{format: "%{remote_ip} %{remote_port}", resp: resp, want: "::1 9870"},
+		{format: "%{time_connect} %{time_total}", resp: resp, want: "0.001500 2.000000"},
+		{format: "100%% \\t\\\\ \\x", resp: resp, want: "100% \t\\ \\x"},
+		{format: "[%{unknown}]", resp: resp, want: "[]"},
+		{format: "%{http_code", resp: resp, want: "%{http_code"},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := writeOut(&out, tt.format, tt.resp, stats()); err != nil {
+			t.Errorf("writeOut(%q) error = %v", tt.format, err)
+			continue
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("writeOut(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}